@@ -1,11 +1,17 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 )
@@ -21,6 +27,140 @@ type HelmRepo struct {
 	URL  string `json:"url"`
 }
 
+// RepoCredentials regroupe l'authentification et la configuration TLS d'un repository Helm.
+type RepoCredentials struct {
+	Username              string `json:"username"`
+	Password              string `json:"password"`
+	CAFile                string `json:"caFile"`
+	CertFile              string `json:"certFile"`
+	KeyFile               string `json:"keyFile"`
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify"`
+}
+
+// LoadRepoCredentials construit les credentials d'un repository à partir du fichier
+// de credentials (un objet JSON indexé par nom de repository), puis des variables
+// d'environnement HELM_REPO_USERNAME et HELM_REPO_PASSWORD qui sont prioritaires.
+func LoadRepoCredentials(repoName, credentialsFile string) (RepoCredentials, error) {
+	var creds RepoCredentials
+	if credentialsFile != "" {
+		content, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return creds, fmt.Errorf("failed to read credentials file: %v", err)
+		}
+		var all map[string]RepoCredentials
+		if err := json.Unmarshal(content, &all); err != nil {
+			return creds, fmt.Errorf("failed to parse credentials file: %v", err)
+		}
+		creds = all[repoName]
+	}
+	if username := os.Getenv("HELM_REPO_USERNAME"); username != "" {
+		creds.Username = username
+	}
+	if password := os.Getenv("HELM_REPO_PASSWORD"); password != "" {
+		creds.Password = password
+	}
+	if creds.Password != "" && creds.Username == "" {
+		return creds, fmt.Errorf("a password is set for repository %s but no username", repoName)
+	}
+	return creds, nil
+}
+
+// tlsArgs retourne les options TLS à passer aux commandes helm.
+func (c RepoCredentials) tlsArgs() []string {
+	var args []string
+	if c.CAFile != "" {
+		args = append(args, "--ca-file", c.CAFile)
+	}
+	if c.CertFile != "" {
+		args = append(args, "--cert-file", c.CertFile)
+	}
+	if c.KeyFile != "" {
+		args = append(args, "--key-file", c.KeyFile)
+	}
+	if c.InsecureSkipTLSVerify {
+		args = append(args, "--insecure-skip-tls-verify")
+	}
+	return args
+}
+
+// TLSConfig construit la configuration TLS utilisée pour les accès HTTP faits par le programme lui-même.
+func (c RepoCredentials) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipTLSVerify}
+	if c.CAFile != "" {
+		caBundle, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificate found in CA bundle %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// NewHTTPClient retourne un client HTTP authentifié pour le repository.
+func (c RepoCredentials) NewHTTPClient() (*http.Client, error) {
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}, nil
+}
+
+// CheckHelmRepoAccess vérifie que l'index du repository est accessible avec les credentials fournis.
+func CheckHelmRepoAccess(repoURL string, creds RepoCredentials) error {
+	client, err := creds.NewHTTPClient()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(repoURL, "/")+"/index.yaml", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	if creds.Username != "" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach repository index: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("repository index returned %s", resp.Status)
+	}
+	return nil
+}
+
+// AddHelmRepo ajoute le repository Helm. Le mot de passe est transmis sur l'entrée
+// standard pour ne pas apparaître dans la liste des processus ; helm le conserve
+// ensuite avec le repository pour les commandes search et fetch.
+func AddHelmRepo(repoName, repoURL string, creds RepoCredentials) error {
+	args := []string{"repo", "add", repoName, repoURL}
+	if creds.Username != "" {
+		args = append(args, "--username", creds.Username, "--password-stdin")
+	}
+	args = append(args, creds.tlsArgs()...)
+	cmd := exec.Command("helm", args...)
+	cmd.Stdin = strings.NewReader(creds.Password)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to execute helm repo add: %v", err)
+	}
+	return nil
+}
+
 // CheckHelmRepoExists vérifie si un repository Helm existe.
 func CheckHelmRepoExists(repoName string) bool {
 	cmd := exec.Command("helm", "repo", "list", "--output", "json")
@@ -72,12 +212,13 @@ func getHelmChartVersions(repo, chart string) ([]string, error) {
 	return versions, nil
 }
 
-func fetchHelmChart(chartName, version, destination string) error {
+func fetchHelmChart(chartName, version, destination string, creds RepoCredentials) error {
 	// Exécute la commande helm fetch
 	if destination == "" {
 		destination = "."
 	}
-	cmd := exec.Command("helm", "fetch", chartName, "--version", version, "--untar", "--untardir", destination)
+	args := append([]string{"fetch", chartName, "--version", version, "--untar", "--untardir", destination}, creds.tlsArgs()...)
+	cmd := exec.Command("helm", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -109,6 +250,16 @@ func getForm[T comparable](selects ...*huh.Select[T]) *huh.Form {
 
 func main() {
 	var dl Download
+	var repoURL, credentialsFile string
+	var creds RepoCredentials
+
+	flag.StringVar(&repoURL, "repo-url", "", "URL used to add the repository when it is not configured yet")
+	flag.StringVar(&credentialsFile, "credentials-file", os.Getenv("HELM_REPO_CREDENTIALS_FILE"), "JSON file holding repository credentials indexed by repository name")
+	flag.StringVar(&creds.CAFile, "ca-file", "", "CA bundle used to verify the repository certificate")
+	flag.StringVar(&creds.CertFile, "cert-file", "", "client certificate used to authenticate against the repository")
+	flag.StringVar(&creds.KeyFile, "key-file", "", "client key used to authenticate against the repository")
+	flag.BoolVar(&creds.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "skip the repository certificate verification")
+	flag.Parse()
 
 	// firstForm := huh.NewForm(
 	// 	huh.NewGroup(
@@ -137,15 +288,42 @@ func main() {
 		huh.NewSelect[string]().Title("select Helm chart from repository").Options(huh.NewOption("NGINX", "nginx"), huh.NewOption("POSTGRESQL", "postgres")).Value(&dl.chart),
 	)
 
-	err := firstForm.Run()
-	if err != nil {
+	if err := firstForm.Run(); err != nil {
 		log.Fatal(err)
 	}
 
+	fileCreds, err := LoadRepoCredentials(dl.repo, credentialsFile)
+	if err != nil {
+		fmt.Printf("Erreur lors du chargement des credentials : %v\n", err)
+		os.Exit(1)
+	}
+	// Les options de la ligne de commande sont prioritaires sur le fichier de credentials
+	if creds.CAFile != "" {
+		fileCreds.CAFile = creds.CAFile
+	}
+	if creds.CertFile != "" {
+		fileCreds.CertFile = creds.CertFile
+	}
+	if creds.KeyFile != "" {
+		fileCreds.KeyFile = creds.KeyFile
+	}
+	fileCreds.InsecureSkipTLSVerify = fileCreds.InsecureSkipTLSVerify || creds.InsecureSkipTLSVerify
+	creds = fileCreds
+
 	exists := CheckHelmRepoExists(dl.repo)
 
 	if exists {
 		fmt.Printf("Le repository Helm '%s' existe.\n", dl.repo)
+	} else if repoURL != "" {
+		if err := CheckHelmRepoAccess(repoURL, creds); err != nil {
+			fmt.Printf("Erreur le repository Helm '%s' n'est pas accessible : %v\n", dl.repo, err)
+			os.Exit(1)
+		}
+		if err := AddHelmRepo(dl.repo, repoURL, creds); err != nil {
+			fmt.Printf("Erreur lors de l'ajout du repository Helm '%s' : %v\n", dl.repo, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Le repository Helm '%s' a été ajouté.\n", dl.repo)
 	} else {
 		fmt.Printf("Erreur le repository Helm '%s' n'existe pas.\n", dl.repo)
 		os.Exit(1)
@@ -182,7 +360,7 @@ func main() {
 
 	chartFullName := fmt.Sprintf("%s/%s", dl.repo, dl.chart)
 
-	err = fetchHelmChart(chartFullName, dl.version, "")
+	err = fetchHelmChart(chartFullName, dl.version, "", creds)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	}
@@ -26,6 +26,8 @@ var (
 	EvenRowStyle = CellStyle.Copy().Foreground(gray)
 	// BorderStyle is the lipgloss style used for the table border.
 	BorderStyle = lipgloss.NewStyle().Foreground(purple)
+	// TitleStyle is the lipgloss style used for the section titles of a report.
+	TitleStyle = re.NewStyle().Foreground(purple).Bold(true).Underline(true)
 )
 
 func CreateOptionsFromStrings(strings []string) []huh.Option[string] {
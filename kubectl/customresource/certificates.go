@@ -0,0 +1,109 @@
+package customresource
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"kubectl/health"
+	"kubectl/logger"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+)
+
+// certManagerGroup is the API group of the cert-manager resources.
+const certManagerGroup = "cert-manager.io"
+
+// ExpiryWindow is the time before their expiry from which the certificates are reported.
+var ExpiryWindow = 14 * 24 * time.Hour
+
+var secretGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+func (cr *CustomResource) isCertificate() bool {
+	return cr.getGroup() == certManagerGroup && cr.getResource() == "certificates"
+}
+
+// certificateNotAfter returns the expiry date of a Certificate from its status, or from the certificate
+// stored in its TLS secret when the status does not report it and a client is given.
+func certificateNotAfter(kubeDynamicClient dynamic.Interface, custom *unstructured.Unstructured) (time.Time, bool) {
+	if notAfter, _, _ := unstructured.NestedString(custom.Object, "status", "notAfter"); notAfter != "" {
+		expiry, err := time.Parse(time.RFC3339, notAfter)
+		if err == nil {
+			return expiry, true
+		}
+		logger.Logger.Debug("Unreadable notAfter", "name", custom.GetName(), "err", err)
+	}
+	secretName, _, _ := unstructured.NestedString(custom.Object, "spec", "secretName")
+	if kubeDynamicClient == nil || secretName == "" {
+		return time.Time{}, false
+	}
+	expiry, err := secretNotAfter(kubeDynamicClient, custom.GetNamespace(), secretName)
+	if err != nil {
+		logger.Logger.Debug("Unreadable certificate secret", "name", custom.GetName(), "secret", secretName, "err", err)
+		return time.Time{}, false
+	}
+	return expiry, true
+}
+
+// secretNotAfter decodes the first certificate of the tls.crt key of a TLS secret and returns its expiry date.
+func secretNotAfter(kubeDynamicClient dynamic.Interface, namespace string, name string) (time.Time, error) {
+	secret, err := kubeDynamicClient.Resource(secretGVR).Namespace(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	encoded, _, _ := unstructured.NestedString(secret.Object, "data", "tls.crt")
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid tls.crt encoding: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("no PEM certificate in tls.crt")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return certificate.NotAfter, nil
+}
+
+// checkExpiry reports an expired certificate as failed, and a certificate expiring within ExpiryWindow
+// as a warning when it has no other issue.
+func checkExpiry(CRHealth *health.ResourceHealth, notAfter time.Time) {
+	remaining := time.Until(notAfter)
+	date := notAfter.UTC().Format(time.RFC3339)
+	switch {
+	case remaining <= 0:
+		CRHealth.Status = StatusFailed
+		CRHealth.Severity = statusSeverity[StatusFailed]
+		CRHealth.Reason = "Expired"
+		CRHealth.Message = fmt.Sprintf("certificate expired on %s", date)
+	case remaining <= ExpiryWindow && !CRHealth.IsIssue():
+		CRHealth.Severity = health.SeverityWarning
+		CRHealth.Reason = "ExpiringSoon"
+		CRHealth.Message = fmt.Sprintf("certificate expires in %s, on %s", duration.HumanDuration(remaining), date)
+	}
+}
+
+// issuerReference reads the issuerRef of a Certificate or a CertificateRequest. ClusterIssuers are referenced
+// without namespace, the other issuers are in the namespace of the object.
+func issuerReference(custom *unstructured.Unstructured) (health.Reference, bool) {
+	issuerRef, found, err := unstructured.NestedStringMap(custom.Object, "spec", "issuerRef")
+	if err != nil || !found || issuerRef["name"] == "" {
+		return health.Reference{}, false
+	}
+	kind := issuerRef["kind"]
+	if kind == "" {
+		kind = "Issuer"
+	}
+	if kind == "ClusterIssuer" {
+		return health.Reference{Kind: kind, Name: issuerRef["name"]}, true
+	}
+	return reference(custom, kind, "", issuerRef["name"]), true
+}
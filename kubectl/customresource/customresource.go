@@ -3,7 +3,7 @@ package customresource
 import (
 	"context"
 	"fmt"
-	"kubectl/charm"
+	"kubectl/health"
 	"kubectl/k8s"
 	"kubectl/logger"
 	"time"
@@ -11,8 +11,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
 )
 
 type CustomResourceDefinition interface {
@@ -20,21 +20,29 @@ type CustomResourceDefinition interface {
 	getGroup() string
 	setVersion(version string)
 	getVersion() string
-	setKind(kind string)
-	getKind() string
+	setResource(resource string)
+	getResource() string
 	getSuccessCondition() string
-	getPrettyName() string
-	GetCRList(kubeClient dynamic.Interface, kubeStaticClient kubernetes.Interface, namespace string) ([][]string, []map[string]string)
-	DisplayCRIssue(CRListIssue []map[string]string)
-	AnalyzeCRStatus(kubeClient dynamic.Interface, kubeStaticClient kubernetes.Interface, namespace string)
+	getSuccessReason() string
+	setClusterScoped(clusterScoped bool)
+	clone() CustomResourceDefinition
+	GetPrettyName() string
+	GetKind() string
+	IsClusterScoped() bool
+	GetGVR() schema.GroupVersionResource
+	EvaluateResource(custom *unstructured.Unstructured, events k8s.EventIndex) health.ResourceHealth
+	GetCRList(kubeClient dynamic.Interface, events k8s.EventIndex, namespace string) ([]health.ResourceHealth, error)
 }
 
 type CustomResource struct {
 	group            string
 	version          string
+	resource         string
 	kind             string
 	successCondition string
+	successReason    string
 	prettyName       string
+	clusterScoped    bool
 }
 
 func (cr *CustomResource) setGroup(group string) {
@@ -52,263 +60,167 @@ func (cr *CustomResource) setVersion(version string) {
 func (cr *CustomResource) getVersion() string {
 	return cr.version
 }
-func (cr *CustomResource) setKind(kind string) {
-	cr.kind = kind
+func (cr *CustomResource) setResource(resource string) {
+	cr.resource = resource
 }
 
-func (cr *CustomResource) getKind() string {
-	return cr.kind
+func (cr *CustomResource) getResource() string {
+	return cr.resource
 }
 
 func (cr *CustomResource) getSuccessCondition() string {
 	return cr.successCondition
 }
 
-func (cr *CustomResource) getPrettyName() string {
-	return cr.prettyName
+func (cr *CustomResource) getSuccessReason() string {
+	return cr.successReason
 }
 
-type ExternalSecret struct {
-	CustomResource
+func (cr *CustomResource) setClusterScoped(clusterScoped bool) {
+	cr.clusterScoped = clusterScoped
 }
 
-type Kustomization struct {
-	CustomResource
+func (cr *CustomResource) clone() CustomResourceDefinition {
+	clone := *cr
+	return &clone
 }
 
-type GitRepository struct {
-	CustomResource
+func (cr *CustomResource) GetPrettyName() string {
+	return cr.prettyName
 }
 
-type HelmRelease struct {
-	CustomResource
+// GetKind returns the Kubernetes kind of the custom resource, used to match its events and the references to it.
+func (cr *CustomResource) GetKind() string {
+	return cr.kind
 }
 
-type HelmRepository struct {
-	CustomResource
+// IsClusterScoped reports whether the custom resource is cluster scoped, as discovered on the cluster.
+func (cr *CustomResource) IsClusterScoped() bool {
+	return cr.clusterScoped
 }
 
-func GetCRD(crdType string, group string, kind string, version string) CustomResourceDefinition {
-	if crdType == "externalsecret" {
-		return NewExternalSecret(group, kind, version)
-	}
-	if crdType == "kustomization" {
-		return NewKustomization(group, kind, version)
-	}
-	if crdType == "gitrepository" {
-		return NewGitRepository(group, kind, version)
-	}
-	if crdType == "helmrelease" {
-		return NewHelmRelease(group, kind, version)
-	}
-	if crdType == "helmrepository" {
-		return NewHelmRepository(group, kind, version)
-	}
-	logger.ErrHandle(fmt.Errorf("Wrong CRD type passed : %v", crdType))
-	return nil
+// GetGVR returns the group, version and resource used to list the custom resources.
+func (cr *CustomResource) GetGVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: cr.getGroup(), Version: cr.getVersion(), Resource: cr.getResource()}
 }
 
-func NewExternalSecret(group string, kind string, version string) CustomResourceDefinition {
-	if group == "" {
-		group = "external-secrets.io"
-	}
-	if kind == "" {
-		kind = "externalsecrets"
-	}
-	if version == "" {
-		version = "v1beta1"
-	}
-	return &ExternalSecret{
-		CustomResource: CustomResource{
-			group:            group,
-			kind:             kind,
-			version:          version,
-			successCondition: "SecretSynced",
-			prettyName:       "ExternalSecret",
-		},
+// NewCustomResource creates the custom resource analyzed for a registry entry.
+func NewCustomResource(entry Entry) CustomResourceDefinition {
+	return &CustomResource{
+		group:            entry.Group,
+		version:          entry.Version,
+		resource:         entry.Resource,
+		kind:             entry.Kind,
+		successCondition: entry.SuccessCondition,
+		successReason:    entry.SuccessReason,
+		prettyName:       entry.PrettyName,
 	}
 }
 
-func NewKustomization(group string, kind string, version string) CustomResourceDefinition {
-	if group == "" {
-		group = "kustomize.toolkit.fluxcd.io"
-	}
-	if kind == "" {
-		kind = "kustomizations"
-	}
-	if version == "" {
-		version = "v1"
+// GetCRList returns the health of every custom resource of the namespace, or of the cluster when the namespace is empty.
+// A nil slice is returned when there is none.
+func (cr *CustomResource) GetCRList(kubeDynamicClient dynamic.Interface, events k8s.EventIndex, namespace string) ([]health.ResourceHealth, error) {
+	logger.Logger.Debug("Looking for customResource", "kind", cr.GetPrettyName(), "namespace", namespace)
+	customResources, err := kubeDynamicClient.Resource(cr.GetGVR()).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		if namespace == "" {
+			return nil, fmt.Errorf("failed listing %s: %w", cr.GetPrettyName(), err)
+		}
+		return nil, fmt.Errorf("failed listing %s in %s: %w", cr.GetPrettyName(), namespace, err)
 	}
-	return &Kustomization{
-		CustomResource: CustomResource{
-			group:            group,
-			kind:             kind,
-			version:          version,
-			successCondition: "ReconciliationSucceeded",
-			prettyName:       "Kustomization",
-		},
+	if customResources == nil || len(customResources.Items) == 0 {
+		logger.Logger.Info("No CustomResource found", "kind", cr.GetPrettyName(), "namespace", namespace)
+		return nil, nil
 	}
-}
+	CRList := make([]health.ResourceHealth, 0, len(customResources.Items))
 
-func NewGitRepository(group string, kind string, version string) CustomResourceDefinition {
-	if group == "" {
-		group = "source.toolkit.fluxcd.io"
-	}
-	if kind == "" {
-		kind = "gitrepositories"
-	}
-	if version == "" {
-		version = "v1"
-	}
-	return &GitRepository{
-		CustomResource: CustomResource{
-			group:            group,
-			kind:             kind,
-			version:          version,
-			successCondition: "Succeeded",
-			prettyName:       "GitRepository",
-		},
+	for i := range customResources.Items {
+		CRList = append(CRList, cr.evaluate(kubeDynamicClient, &customResources.Items[i], events))
 	}
+	return CRList, nil
 }
 
-func NewHelmRelease(group string, kind string, version string) CustomResourceDefinition {
-	if group == "" {
-		group = "helm.toolkit.fluxcd.io"
-	}
-	if kind == "" {
-		kind = "helmreleases"
-	}
-	if version == "" {
-		version = "v2beta1"
-	}
-	return &HelmRelease{
-		CustomResource: CustomResource{
-			group:            group,
-			kind:             kind,
-			version:          version,
-			successCondition: "ReconciliationSucceeded",
-			prettyName:       "HelmRelease",
-		},
-	}
+// EvaluateResource returns the health of a custom resource with its dependencies, and its warning events
+// when it is an issue.
+func (cr *CustomResource) EvaluateResource(custom *unstructured.Unstructured, events k8s.EventIndex) health.ResourceHealth {
+	return cr.evaluate(nil, custom, events)
 }
 
-func NewHelmRepository(group string, kind string, version string) CustomResourceDefinition {
-	if group == "" {
-		group = "source.toolkit.fluxcd.io"
-	}
-	if kind == "" {
-		kind = "helmrepositories"
-	}
-	if version == "" {
-		version = "v2beta1"
+// evaluate is EvaluateResource with a client used to read the TLS secret of the certificates
+// that do not report their expiry date. The secrets are not read when the client is nil.
+func (cr *CustomResource) evaluate(kubeDynamicClient dynamic.Interface, custom *unstructured.Unstructured, events k8s.EventIndex) health.ResourceHealth {
+	CRHealth := cr.evaluateResource(custom)
+	if cr.isCertificate() {
+		if notAfter, ok := certificateNotAfter(kubeDynamicClient, custom); ok {
+			checkExpiry(&CRHealth, notAfter)
+		}
 	}
-	return &HelmRepository{
-		CustomResource: CustomResource{
-			group:            group,
-			kind:             kind,
-			version:          version,
-			successCondition: "Succeeded",
-			prettyName:       "HelmRepository",
-		},
+	CRHealth.DependsOn = dependencies(custom, cr.GetKind())
+	if CRHealth.IsIssue() {
+		CRHealth.Events = k8s.ToHealthEvents(events.For(cr.GetKind(), CRHealth.Name))
 	}
+	return CRHealth
 }
 
-func (cr *CustomResource) GetCRList(kubeDynamicClient dynamic.Interface, kubeStaticClient kubernetes.Interface, namespace string) ([][]string, []map[string]string) {
-	logger.Logger.Debug("Looking for customResource", "kind", cr.getPrettyName(), "namespace", namespace)
-	var customResource = schema.GroupVersionResource{Group: cr.getGroup(), Version: cr.getVersion(), Resource: cr.getKind()}
-	customResources, err := kubeDynamicClient.Resource(customResource).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
-	if customResources == nil || len(customResources.Items) == 0 {
-		logger.Logger.Info("No CustomResource found", "kind", cr.getPrettyName(), "namespace", namespace)
-		return nil, nil
+// evaluateResource reads the conditions of a custom resource and evaluates its readiness.
+// Malformed conditions are skipped, and a resource without any condition is reported as
+// Pending/NoStatus instead of stopping the scan.
+func (cr *CustomResource) evaluateResource(custom *unstructured.Unstructured) health.ResourceHealth {
+	createdAt := custom.GetCreationTimestamp().Time
+	CRHealth := health.ResourceHealth{
+		Kind:      cr.GetKind(),
+		Name:      custom.GetName(),
+		Namespace: custom.GetNamespace(),
+		CreatedAt: &createdAt,
 	}
-	logger.ErrHandle(err)
-	CRList := make([][]string, 0)
-	CRListIssue := make([]map[string]string, 0)
-
-	for _, custom := range customResources.Items {
-
-		statusMap, foundStatus, err := unstructured.NestedMap(custom.Object, "status")
-		logger.ErrHandle(err)
-
-		if foundStatus {
-			conditions, foundConditions, err := unstructured.NestedSlice(statusMap, "conditions")
-			logger.ErrHandle(err)
 
-			if foundConditions {
-				var latestCondition map[string]interface{}
-				latestTime := time.Time{}
-				latestIndex := -1
-
-				for i, condition := range conditions {
-					conditionMap, ok := condition.(map[string]interface{})
-					if !ok {
-						logger.Logger.Fatal("condition is not a Map object", "object", custom.Object)
-					}
-					transitionTimeStr, _ := conditionMap["lastTransitionTime"].(string)
-					transitionTime, err := time.Parse(time.RFC3339, transitionTimeStr)
-					logger.ErrHandle(err)
-
-					if transitionTime.After(latestTime) || (transitionTime.Equal(latestTime) && i > latestIndex) {
-						latestTime = transitionTime
-						latestCondition = conditionMap
-						latestIndex = i
-					}
-				}
-				if latestCondition != nil {
-					reason := latestCondition["reason"].(string)
-					status := latestCondition["status"].(string)
-					message := latestCondition["message"].(string)
-
-					row := []string{
-						custom.Object["metadata"].(map[string]interface{})["name"].(string),
-						reason,
-						status,
-						message,
-					}
-					CRList = append(CRList, row)
-
-					if reason != cr.getSuccessCondition() {
-						CREvents := k8s.GetWarningEventsFromResource(kubeStaticClient, cr.getPrettyName(), namespace, custom.Object["metadata"].(map[string]interface{})["name"].(string))
-						issue := map[string]string{
-							"Name":    custom.Object["metadata"].(map[string]interface{})["name"].(string),
-							"Status":  reason,
-							"Ready":   status,
-							"Message": message,
-						}
-						for _, event := range CREvents {
-							issue["EventMessage"] += event.Message
-						}
-						CRListIssue = append(CRListIssue, issue)
-					}
-				}
-			} else {
-				logger.Logger.Fatal("No sub-entry 'conditions' found in entry 'status'", "object", custom.Object)
-			}
-		} else {
-			logger.Logger.Fatal("No entry 'status' found", "object", custom.Object)
-		}
+	conditions, _, err := unstructured.NestedSlice(custom.Object, "status", "conditions")
+	if err != nil {
+		logger.Logger.Debug("Unreadable conditions", "kind", cr.GetPrettyName(), "name", custom.GetName(), "err", err)
 	}
-	return CRList, CRListIssue
-}
+	latestTime := time.Time{}
+	latestIndex := -1
+	for _, condition := range conditions {
+		conditionMap, ok := condition.(map[string]interface{})
+		if !ok {
+			logger.Logger.Debug("Skipping condition that is not a map", "kind", cr.GetPrettyName(), "name", custom.GetName())
+			continue
+		}
+		conditionType, _ := conditionMap["type"].(string)
+		conditionStatus, _ := conditionMap["status"].(string)
+		conditionReason, _ := conditionMap["reason"].(string)
+		conditionMessage, _ := conditionMap["message"].(string)
+		healthCondition := health.Condition{
+			Type:    conditionType,
+			Status:  conditionStatus,
+			Reason:  conditionReason,
+			Message: conditionMessage,
+		}
+		transitionTimeStr, _ := conditionMap["lastTransitionTime"].(string)
+		transitionTime, err := time.Parse(time.RFC3339, transitionTimeStr)
+		if err == nil {
+			healthCondition.LastTransitionTime = &transitionTime
+		}
+		CRHealth.Conditions = append(CRHealth.Conditions, healthCondition)
 
-func (cr *CustomResource) DisplayCRIssue(CRListIssue []map[string]string) {
-	if len(CRListIssue) > 0 {
-		logger.Logger.Error("ISSUE DETECTED", "kind", cr.getPrettyName())
-		for _, pb := range CRListIssue {
-			logger.Logger.Error("Unsynced/NotReady", "kind", cr.getPrettyName(), "name", pb["Name"], "status", pb["Status"], "ready", pb["Ready"], "errors", pb["EventMessage"]+"\n")
+		index := len(CRHealth.Conditions) - 1
+		if transitionTime.After(latestTime) || (transitionTime.Equal(latestTime) && index > latestIndex) {
+			latestTime = transitionTime
+			latestIndex = index
 		}
-	} else {
-		logger.Logger.Info("All CustomResources are healthy", "kind", cr.getPrettyName())
 	}
-}
 
-func (cr *CustomResource) AnalyzeCRStatus(kubeDynamicClient dynamic.Interface, kubeStaticClient kubernetes.Interface, namespace string) {
-	CRList, CRListIssue := cr.GetCRList(kubeDynamicClient, kubeStaticClient, namespace)
-	if CRList != nil {
-		logger.Logger.Debug("Listing customResource", "kind", cr.getPrettyName(), "namespace", namespace)
-		charm.CreateObjectArray(CRList, make([]string, 0))
-		if CRListIssue != nil {
-			cr.DisplayCRIssue(CRListIssue)
+	if latestIndex < 0 {
+		if suspended, _, _ := unstructured.NestedBool(custom.Object, "spec", "suspend"); suspended {
+			cr.evaluateReadiness(custom, &CRHealth, health.Condition{})
+			return CRHealth
 		}
-	}
+		CRHealth.Status = "Pending"
+		CRHealth.Reason = "NoStatus"
+		CRHealth.Severity = health.SeverityWarning
+		CRHealth.Message = fmt.Sprintf("no status reported, created %s ago", duration.HumanDuration(time.Since(createdAt)))
+		return CRHealth
+	}
+	cr.evaluateReadiness(custom, &CRHealth, CRHealth.Conditions[latestIndex])
+	return CRHealth
 }
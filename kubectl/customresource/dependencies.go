@@ -0,0 +1,50 @@
+package customresource
+
+import (
+	"kubectl/health"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// sourceRefPaths are the fields of the Flux objects pointing to their source.
+var sourceRefPaths = [][]string{
+	{"spec", "sourceRef"},
+	{"spec", "chart", "spec", "sourceRef"},
+}
+
+// dependencies reads the sources and the dependsOn list of a Flux object, and the issuer of a cert-manager object.
+// References without namespace point to the namespace of the object, and dependsOn entries to objects of the same kind.
+func dependencies(custom *unstructured.Unstructured, kind string) []health.Reference {
+	var references []health.Reference
+	for _, path := range sourceRefPaths {
+		sourceRef, found, err := unstructured.NestedStringMap(custom.Object, path...)
+		if err != nil || !found || sourceRef["kind"] == "" || sourceRef["name"] == "" {
+			continue
+		}
+		references = append(references, reference(custom, sourceRef["kind"], sourceRef["namespace"], sourceRef["name"]))
+	}
+	if issuer, ok := issuerReference(custom); ok {
+		references = append(references, issuer)
+	}
+	dependsOn, _, _ := unstructured.NestedSlice(custom.Object, "spec", "dependsOn")
+	for _, dependency := range dependsOn {
+		dependencyMap, ok := dependency.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := dependencyMap["name"].(string)
+		namespace, _ := dependencyMap["namespace"].(string)
+		if name == "" {
+			continue
+		}
+		references = append(references, reference(custom, kind, namespace, name))
+	}
+	return references
+}
+
+func reference(custom *unstructured.Unstructured, kind string, namespace string, name string) health.Reference {
+	if namespace == "" {
+		namespace = custom.GetNamespace()
+	}
+	return health.Reference{Kind: kind, Namespace: namespace, Name: name}
+}
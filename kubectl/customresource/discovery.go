@@ -0,0 +1,85 @@
+package customresource
+
+import (
+	"fmt"
+	"kubectl/logger"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+)
+
+// ResolveVersions asks the discovery API which version of each custom resource is served, and whether it is
+// cluster scoped. The version of the registry entry is used when it is served, the preferred version of the
+// group otherwise. Custom resources whose CRD is not installed are skipped.
+func ResolveVersions(discoveryClient discovery.DiscoveryInterface, crds []CustomResourceDefinition) ([]CustomResourceDefinition, error) {
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed discovering API groups: %w", err)
+	}
+	servedResources := map[string]*metav1.APIResourceList{}
+	serves := func(groupVersion string, resource string) *metav1.APIResource {
+		resources, ok := servedResources[groupVersion]
+		if !ok {
+			var discoveryErr error
+			resources, discoveryErr = discoveryClient.ServerResourcesForGroupVersion(groupVersion)
+			if discoveryErr != nil {
+				logger.Logger.Debug("Failed discovering resources", "groupVersion", groupVersion, "err", discoveryErr)
+				resources = nil
+			}
+			servedResources[groupVersion] = resources
+		}
+		if resources == nil {
+			return nil
+		}
+		for i := range resources.APIResources {
+			if resources.APIResources[i].Name == resource {
+				return &resources.APIResources[i]
+			}
+		}
+		return nil
+	}
+
+	resolved := make([]CustomResourceDefinition, 0, len(crds))
+	for _, cr := range crds {
+		var group *metav1.APIGroup
+		for i := range groups.Groups {
+			if groups.Groups[i].Name == cr.getGroup() {
+				group = &groups.Groups[i]
+			}
+		}
+		if group == nil {
+			logger.Logger.Info("CustomResourceDefinition not installed, skipping", "kind", cr.GetPrettyName(), "group", cr.getGroup())
+			continue
+		}
+
+		candidates := make([]string, 0, len(group.Versions)+2)
+		if cr.getVersion() != "" {
+			candidates = append(candidates, cr.getVersion())
+		}
+		candidates = append(candidates, group.PreferredVersion.Version)
+		for _, version := range group.Versions {
+			candidates = append(candidates, version.Version)
+		}
+
+		version := ""
+		var apiResource *metav1.APIResource
+		for _, candidate := range candidates {
+			if apiResource = serves(cr.getGroup()+"/"+candidate, cr.getResource()); apiResource != nil {
+				version = candidate
+				break
+			}
+		}
+		if version == "" {
+			logger.Logger.Info("CustomResourceDefinition not installed, skipping", "kind", cr.GetPrettyName(), "group", cr.getGroup(), "resource", cr.getResource())
+			continue
+		}
+		if cr.getVersion() != "" && version != cr.getVersion() {
+			logger.Logger.Debug("Configured version not served, using the preferred one", "kind", cr.GetPrettyName(), "configured", cr.getVersion(), "version", version)
+		}
+		served := cr.clone()
+		served.setVersion(version)
+		served.setClusterScoped(!apiResource.Namespaced)
+		resolved = append(resolved, served)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,85 @@
+package customresource
+
+import (
+	"fmt"
+	"kubectl/health"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Statuses a custom resource is classified in, following the kstatus conventions.
+const (
+	StatusCurrent    = "Current"
+	StatusInProgress = "InProgress"
+	StatusFailed     = "Failed"
+	StatusSuspended  = "Suspended"
+	StatusUnknown    = "Unknown"
+)
+
+var statusSeverity = map[string]health.Severity{
+	StatusCurrent:    health.SeverityOK,
+	StatusInProgress: health.SeverityWarning,
+	StatusSuspended:  health.SeverityWarning,
+	StatusUnknown:    health.SeverityWarning,
+	StatusFailed:     health.SeverityError,
+}
+
+// evaluateReadiness classifies the custom resource from its suspend flag, its observed
+// generation and its Stalled, Reconciling and Ready conditions. The success criteria of
+// the registry entry are only used for resources without a Ready condition.
+func (cr *CustomResource) evaluateReadiness(custom *unstructured.Unstructured, CRHealth *health.ResourceHealth, latestCondition health.Condition) {
+	status, reason, message := cr.classify(custom, CRHealth, latestCondition)
+	CRHealth.Status = status
+	CRHealth.Severity = statusSeverity[status]
+	CRHealth.Reason = reason
+	CRHealth.Message = message
+	CRHealth.Ready = CRHealth.ConditionStatus("Ready")
+	if CRHealth.Ready == "" {
+		CRHealth.Ready = latestCondition.Status
+	}
+}
+
+func (cr *CustomResource) classify(custom *unstructured.Unstructured, CRHealth *health.ResourceHealth, latestCondition health.Condition) (string, string, string) {
+	if suspended, _, _ := unstructured.NestedBool(custom.Object, "spec", "suspend"); suspended {
+		return StatusSuspended, "Suspended", "reconciliation is suspended"
+	}
+
+	generation := custom.GetGeneration()
+	observedGeneration, found, _ := unstructured.NestedInt64(custom.Object, "status", "observedGeneration")
+	if found && observedGeneration < generation {
+		return StatusInProgress, "OutdatedGeneration", fmt.Sprintf("generation %d not observed yet (observed %d)", generation, observedGeneration)
+	}
+
+	if stalled, ok := CRHealth.Condition("Stalled"); ok && stalled.Status == "True" {
+		return StatusFailed, stalled.Reason, stalled.Message
+	}
+	if reconciling, ok := CRHealth.Condition("Reconciling"); ok && reconciling.Status == "True" {
+		return StatusInProgress, reconciling.Reason, reconciling.Message
+	}
+	if ready, ok := CRHealth.Condition("Ready"); ok {
+		switch ready.Status {
+		case "True":
+			return StatusCurrent, ready.Reason, ready.Message
+		case "False":
+			return StatusFailed, ready.Reason, ready.Message
+		default:
+			return StatusInProgress, ready.Reason, ready.Message
+		}
+	}
+
+	if cr.getSuccessCondition() != "" {
+		if condition, ok := CRHealth.Condition(cr.getSuccessCondition()); ok {
+			if condition.Status == "True" {
+				return StatusCurrent, condition.Reason, condition.Message
+			}
+			return StatusFailed, condition.Reason, condition.Message
+		}
+	}
+	if cr.getSuccessReason() != "" && latestCondition.Reason == cr.getSuccessReason() {
+		return StatusCurrent, latestCondition.Reason, latestCondition.Message
+	}
+	if cr.getSuccessReason() != "" && latestCondition.Status == "False" {
+		return StatusFailed, latestCondition.Reason, latestCondition.Message
+	}
+	return StatusUnknown, latestCondition.Reason, latestCondition.Message
+}
@@ -0,0 +1,165 @@
+package customresource
+
+import (
+	"kubectl/health"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func condition(conditionType string, status string, reason string, transition string) interface{} {
+	return map[string]interface{}{
+		"type":               conditionType,
+		"status":             status,
+		"reason":             reason,
+		"message":            reason + " message",
+		"lastTransitionTime": transition,
+	}
+}
+
+func customObject(generation int64, spec map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	custom := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+		"kind":       "Kustomization",
+		"metadata":   map[string]interface{}{"name": "apps", "namespace": "flux-system"},
+	}}
+	custom.SetGeneration(generation)
+	if spec != nil {
+		custom.Object["spec"] = spec
+	}
+	if status != nil {
+		custom.Object["status"] = status
+	}
+	return custom
+}
+
+func TestEvaluateReadiness(t *testing.T) {
+	const earlier, later = "2024-01-01T00:00:00Z", "2024-01-01T01:00:00Z"
+	tests := []struct {
+		name             string
+		successCondition string
+		successReason    string
+		custom           *unstructured.Unstructured
+		status           string
+		reason           string
+		severity         health.Severity
+	}{
+		{
+			name:     "ready",
+			custom:   customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Ready", "True", "ReconciliationSucceeded", earlier)}}),
+			status:   StatusCurrent,
+			reason:   "ReconciliationSucceeded",
+			severity: health.SeverityOK,
+		},
+		{
+			name:     "not ready",
+			custom:   customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Ready", "False", "BuildFailed", earlier)}}),
+			status:   StatusFailed,
+			reason:   "BuildFailed",
+			severity: health.SeverityError,
+		},
+		{
+			name:     "ready unknown",
+			custom:   customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Ready", "Unknown", "Progressing", earlier)}}),
+			status:   StatusInProgress,
+			reason:   "Progressing",
+			severity: health.SeverityWarning,
+		},
+		{
+			name: "stalled wins over ready",
+			custom: customObject(1, nil, map[string]interface{}{"conditions": []interface{}{
+				condition("Ready", "True", "ReconciliationSucceeded", earlier),
+				condition("Stalled", "True", "InvalidPath", later),
+			}}),
+			status:   StatusFailed,
+			reason:   "InvalidPath",
+			severity: health.SeverityError,
+		},
+		{
+			name: "reconciling",
+			custom: customObject(1, nil, map[string]interface{}{"conditions": []interface{}{
+				condition("Ready", "Unknown", "Progressing", earlier),
+				condition("Reconciling", "True", "ProgressingWithRetry", later),
+			}}),
+			status:   StatusInProgress,
+			reason:   "ProgressingWithRetry",
+			severity: health.SeverityWarning,
+		},
+		{
+			name: "generation not observed",
+			custom: customObject(3, nil, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"conditions":         []interface{}{condition("Ready", "True", "ReconciliationSucceeded", earlier)},
+			}),
+			status:   StatusInProgress,
+			reason:   "OutdatedGeneration",
+			severity: health.SeverityWarning,
+		},
+		{
+			name: "suspended",
+			custom: customObject(1, map[string]interface{}{"suspend": true}, map[string]interface{}{
+				"conditions": []interface{}{condition("Ready", "False", "BuildFailed", earlier)},
+			}),
+			status:   StatusSuspended,
+			reason:   "Suspended",
+			severity: health.SeverityWarning,
+		},
+		{
+			name:     "suspended without status",
+			custom:   customObject(1, map[string]interface{}{"suspend": true}, nil),
+			status:   StatusSuspended,
+			reason:   "Suspended",
+			severity: health.SeverityWarning,
+		},
+		{
+			name:     "no status",
+			custom:   customObject(1, nil, nil),
+			status:   "Pending",
+			reason:   "NoStatus",
+			severity: health.SeverityWarning,
+		},
+		{
+			name:          "success reason on the latest condition",
+			successReason: "SecretSynced",
+			custom: customObject(1, nil, map[string]interface{}{"conditions": []interface{}{
+				condition("Synced", "False", "SecretSyncedError", earlier),
+				condition("Synced", "True", "SecretSynced", later),
+			}}),
+			status:   StatusCurrent,
+			reason:   "SecretSynced",
+			severity: health.SeverityOK,
+		},
+		{
+			name:          "success reason missing",
+			successReason: "SecretSynced",
+			custom:        customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Synced", "False", "SecretSyncedError", earlier)}}),
+			status:        StatusFailed,
+			reason:        "SecretSyncedError",
+			severity:      health.SeverityError,
+		},
+		{
+			name:             "success condition false",
+			successCondition: "Available",
+			custom:           customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Available", "False", "MinimumReplicasUnavailable", earlier)}}),
+			status:           StatusFailed,
+			reason:           "MinimumReplicasUnavailable",
+			severity:         health.SeverityError,
+		},
+		{
+			name:     "no success criteria",
+			custom:   customObject(1, nil, map[string]interface{}{"conditions": []interface{}{condition("Synced", "True", "Synced", earlier)}}),
+			status:   StatusUnknown,
+			reason:   "Synced",
+			severity: health.SeverityWarning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr := &CustomResource{kind: "Kustomization", successCondition: tt.successCondition, successReason: tt.successReason}
+			got := cr.evaluateResource(tt.custom)
+			if got.Status != tt.status || got.Reason != tt.reason || got.Severity != tt.severity {
+				t.Errorf("got %s/%s/%s, want %s/%s/%s", got.Status, got.Reason, got.Severity, tt.status, tt.reason, tt.severity)
+			}
+		})
+	}
+}
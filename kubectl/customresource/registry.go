@@ -0,0 +1,90 @@
+package customresource
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed defaults.yaml
+var defaultRegistry []byte
+
+// Entry declares a custom resource to analyze. Kind is the Kubernetes kind matching the events
+// and the references of the resources, PrettyName the displayed name. Version is optional, the served version is
+// discovered on each cluster. SuccessCondition is a condition type that must be True and
+// SuccessReason the reason expected on the latest condition; they are only used for
+// resources that do not report a Ready condition.
+type Entry struct {
+	PrettyName       string `json:"prettyName"`
+	Kind             string `json:"kind"`
+	Group            string `json:"group"`
+	Version          string `json:"version,omitempty"`
+	Resource         string `json:"resource"`
+	SuccessCondition string `json:"successCondition,omitempty"`
+	SuccessReason    string `json:"successReason,omitempty"`
+}
+
+// Registry is the content of a custom resource configuration file.
+type Registry struct {
+	CustomResources []Entry `json:"customResources"`
+}
+
+func parseRegistry(content []byte) (Registry, error) {
+	var registry Registry
+	if err := yaml.UnmarshalStrict(content, &registry); err != nil {
+		return registry, err
+	}
+	for i, entry := range registry.CustomResources {
+		if entry.PrettyName == "" || entry.Kind == "" || entry.Group == "" || entry.Resource == "" {
+			return registry, fmt.Errorf("entry %d: prettyName, kind, group and resource are required", i)
+		}
+		if entry.SuccessCondition == "" && entry.SuccessReason == "" {
+			return registry, fmt.Errorf("entry %s: successCondition or successReason is required", entry.PrettyName)
+		}
+	}
+	return registry, nil
+}
+
+// DefaultEntries returns the built-in ExternalSecret, Flux and cert-manager custom resources.
+func DefaultEntries() []Entry {
+	registry, err := parseRegistry(defaultRegistry)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default custom resource registry: %v", err))
+	}
+	return registry.CustomResources
+}
+
+// LoadRegistry returns the custom resources to analyze: the default entries merged with
+// the entries of the configuration file, if any.
+func LoadRegistry(path string) ([]CustomResourceDefinition, error) {
+	entries := DefaultEntries()
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		registry, err := parseRegistry(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom resource configuration %s: %w", path, err)
+		}
+		for _, entry := range registry.CustomResources {
+			replaced := false
+			for i := range entries {
+				if entries[i].PrettyName == entry.PrettyName {
+					entries[i] = entry
+					replaced = true
+				}
+			}
+			if !replaced {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	crds := make([]CustomResourceDefinition, 0, len(entries))
+	for _, entry := range entries {
+		crds = append(crds, NewCustomResource(entry))
+	}
+	return crds, nil
+}
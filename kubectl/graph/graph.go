@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"fmt"
+	"kubectl/health"
+	"sort"
+	"strings"
+)
+
+// Node is an object of the dependency graph. Resource is nil when the object is referenced
+// but was not analyzed.
+type Node struct {
+	Reference  health.Reference
+	Resource   *health.ResourceHealth
+	Upstream   []*Node
+	Downstream []*Node
+}
+
+func (n *Node) failing() bool {
+	return n.Resource != nil && n.Resource.IsIssue()
+}
+
+// Graph links the GitOps objects to their sources and dependencies, and the pods to their claims.
+type Graph struct {
+	nodes map[health.Reference]*Node
+}
+
+// Build creates the graph of the resources from their DependsOn references.
+func Build(resources []*health.ResourceHealth) *Graph {
+	g := &Graph{nodes: make(map[health.Reference]*Node)}
+	for _, resource := range resources {
+		g.node(resource.Reference()).Resource = resource
+	}
+	for _, resource := range resources {
+		node := g.nodes[resource.Reference()]
+		for _, reference := range resource.DependsOn {
+			upstream := g.node(reference)
+			node.Upstream = append(node.Upstream, upstream)
+			upstream.Downstream = append(upstream.Downstream, node)
+		}
+	}
+	for _, node := range g.nodes {
+		sortNodes(node.Upstream)
+		sortNodes(node.Downstream)
+	}
+	return g
+}
+
+func (g *Graph) node(reference health.Reference) *Node {
+	node, ok := g.nodes[reference]
+	if !ok {
+		node = &Node{Reference: reference}
+		g.nodes[reference] = node
+	}
+	return node
+}
+
+func sortNodes(nodes []*Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Reference.String() < nodes[j].Reference.String()
+	})
+}
+
+// HasEdges reports whether at least one resource depends on another object.
+func (g *Graph) HasEdges() bool {
+	for _, node := range g.nodes {
+		if len(node.Upstream) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Propagate sets the root cause of every failing resource that depends on a failing object,
+// and lists the collapsed failures in the Downstream field of their root cause.
+// Root causes set by a previous propagation are replaced.
+func (g *Graph) Propagate() {
+	for _, node := range g.nodes {
+		if node.Resource != nil {
+			node.Resource.RootCause = nil
+			node.Resource.Downstream = nil
+		}
+	}
+	for _, node := range g.sorted() {
+		if !node.failing() {
+			continue
+		}
+		root := rootCause(node, map[*Node]bool{})
+		if root == nil || root == node {
+			continue
+		}
+		reference := root.Reference
+		node.Resource.RootCause = &reference
+		root.Resource.Downstream = append(root.Resource.Downstream, node.Reference)
+	}
+}
+
+// rootCause walks up the failing dependencies of a failing node and returns the first failing
+// object whose own dependencies are healthy. Nil is returned when the walk runs into a cycle of
+// failures, which has no single root cause.
+func rootCause(node *Node, visited map[*Node]bool) *Node {
+	visited[node] = true
+	for _, upstream := range node.Upstream {
+		if !upstream.failing() {
+			continue
+		}
+		if visited[upstream] {
+			return nil
+		}
+		return rootCause(upstream, visited)
+	}
+	return node
+}
+
+func (g *Graph) sorted() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sortNodes(nodes)
+	return nodes
+}
+
+// Tree renders the graph from the objects without dependencies down to their dependents.
+// Objects without dependency nor dependent are left out.
+func (g *Graph) Tree() string {
+	var b strings.Builder
+	for _, node := range g.sorted() {
+		if len(node.Upstream) > 0 || len(node.Downstream) == 0 {
+			continue
+		}
+		b.WriteString(label(node) + "\n")
+		writeChildren(&b, node, "", map[*Node]bool{node: true})
+	}
+	return b.String()
+}
+
+func writeChildren(b *strings.Builder, node *Node, prefix string, path map[*Node]bool) {
+	for i, child := range node.Downstream {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Downstream)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if path[child] {
+			b.WriteString(prefix + branch + label(child) + " (cycle)\n")
+			continue
+		}
+		b.WriteString(prefix + branch + label(child) + "\n")
+		path[child] = true
+		writeChildren(b, child, prefix+indent, path)
+		delete(path, child)
+	}
+}
+
+func label(node *Node) string {
+	if node.Resource == nil {
+		return fmt.Sprintf("? %s (not analyzed)", node.Reference)
+	}
+	resource := node.Resource
+	marker := "✓"
+	switch resource.Severity {
+	case health.SeverityError:
+		marker = "✗"
+	case health.SeverityWarning:
+		marker = "!"
+	}
+	text := fmt.Sprintf("%s %s", marker, node.Reference)
+	if resource.IsIssue() {
+		text += fmt.Sprintf(" %s/%s", resource.Status, resource.Reason)
+	}
+	if resource.RootCause != nil {
+		text += " (caused by " + resource.RootCause.String() + ")"
+	}
+	return text
+}
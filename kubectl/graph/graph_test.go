@@ -0,0 +1,138 @@
+package graph
+
+import (
+	"kubectl/health"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func resource(kind string, name string, severity health.Severity, dependsOn ...health.Reference) *health.ResourceHealth {
+	return &health.ResourceHealth{Kind: kind, Namespace: "flux", Name: name, Severity: severity, Status: "Failed", Reason: "Failing", DependsOn: dependsOn}
+}
+
+func ref(kind string, name string) health.Reference {
+	return health.Reference{Kind: kind, Namespace: "flux", Name: name}
+}
+
+func TestPropagate(t *testing.T) {
+	tests := []struct {
+		name       string
+		resources  []*health.ResourceHealth
+		rootCauses map[string]string
+		downstream map[string][]string
+	}{
+		{
+			name: "failing source",
+			resources: []*health.ResourceHealth{
+				resource("GitRepository", "repo", health.SeverityError),
+				resource("Kustomization", "apps", health.SeverityError, ref("GitRepository", "repo")),
+				resource("Kustomization", "healthy", health.SeverityOK, ref("GitRepository", "repo")),
+			},
+			rootCauses: map[string]string{"apps": "GitRepository flux/repo"},
+			downstream: map[string][]string{"repo": {"Kustomization flux/apps"}},
+		},
+		{
+			name: "chain collapses under the first failure",
+			resources: []*health.ResourceHealth{
+				resource("GitRepository", "repo", health.SeverityError),
+				resource("Kustomization", "infra", health.SeverityError, ref("GitRepository", "repo")),
+				resource("Kustomization", "apps", health.SeverityError, ref("Kustomization", "infra")),
+			},
+			rootCauses: map[string]string{"infra": "GitRepository flux/repo", "apps": "GitRepository flux/repo"},
+			downstream: map[string][]string{"repo": {"Kustomization flux/apps", "Kustomization flux/infra"}},
+		},
+		{
+			name: "healthy dependency is not a root cause",
+			resources: []*health.ResourceHealth{
+				resource("GitRepository", "repo", health.SeverityOK),
+				resource("Kustomization", "apps", health.SeverityError, ref("GitRepository", "repo")),
+			},
+		},
+		{
+			name: "dependency not analyzed",
+			resources: []*health.ResourceHealth{
+				resource("Kustomization", "apps", health.SeverityError, ref("GitRepository", "missing")),
+			},
+		},
+		{
+			name: "cycle of failures is not collapsed",
+			resources: []*health.ResourceHealth{
+				resource("Kustomization", "a", health.SeverityError, ref("Kustomization", "b")),
+				resource("Kustomization", "b", health.SeverityError, ref("Kustomization", "a")),
+				resource("Kustomization", "apps", health.SeverityError, ref("Kustomization", "a")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Build(tt.resources)
+			// A second propagation must not duplicate the downstream failures.
+			g.Propagate()
+			g.Propagate()
+			for _, r := range tt.resources {
+				rootCause := ""
+				if r.RootCause != nil {
+					rootCause = r.RootCause.String()
+				}
+				if rootCause != tt.rootCauses[r.Name] {
+					t.Errorf("%s: root cause %q, want %q", r.Name, rootCause, tt.rootCauses[r.Name])
+				}
+				var downstream []string
+				for _, d := range r.Downstream {
+					downstream = append(downstream, d.String())
+				}
+				if !reflect.DeepEqual(downstream, tt.downstream[r.Name]) {
+					t.Errorf("%s: downstream %v, want %v", r.Name, downstream, tt.downstream[r.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestTree(t *testing.T) {
+	resources := []*health.ResourceHealth{
+		resource("GitRepository", "repo", health.SeverityError),
+		resource("Kustomization", "infra", health.SeverityOK, ref("GitRepository", "repo")),
+		resource("Kustomization", "apps", health.SeverityError, ref("Kustomization", "infra"), ref("HelmRepository", "charts")),
+		resource("Kustomization", "alone", health.SeverityError),
+	}
+	resources[1].Status, resources[1].Reason = "Current", ""
+	g := Build(resources)
+	g.Propagate()
+	want := strings.Join([]string{
+		"✗ GitRepository flux/repo Failed/Failing",
+		"└── ✓ Kustomization flux/infra",
+		"    └── ✗ Kustomization flux/apps Failed/Failing",
+		"? HelmRepository flux/charts (not analyzed)",
+		"└── ✗ Kustomization flux/apps Failed/Failing",
+		"",
+	}, "\n")
+	if got := g.Tree(); got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+	if !g.HasEdges() {
+		t.Error("HasEdges() = false, want true")
+	}
+}
+
+func TestTreeCycle(t *testing.T) {
+	resources := []*health.ResourceHealth{
+		resource("GitRepository", "repo", health.SeverityOK),
+		resource("Kustomization", "a", health.SeverityOK, ref("GitRepository", "repo"), ref("Kustomization", "b")),
+		resource("Kustomization", "b", health.SeverityOK, ref("Kustomization", "a")),
+	}
+	for _, r := range resources {
+		r.Status, r.Reason = "Current", ""
+	}
+	want := strings.Join([]string{
+		"✓ GitRepository flux/repo",
+		"└── ✓ Kustomization flux/a",
+		"    └── ✓ Kustomization flux/b",
+		"        └── ✓ Kustomization flux/a (cycle)",
+		"",
+	}, "\n")
+	if got := Build(resources).Tree(); got != want {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, want)
+	}
+}
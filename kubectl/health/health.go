@@ -0,0 +1,238 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Severity ranks how unhealthy an analyzed object is.
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = map[Severity]string{
+	SeverityOK:      "OK",
+	SeverityWarning: "Warning",
+	SeverityError:   "Error",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if strings.EqualFold(name, string(text)) {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", string(text))
+}
+
+// Condition is a status condition reported by an object.
+type Condition struct {
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason,omitempty"`
+	Message            string     `json:"message,omitempty"`
+	LastTransitionTime *time.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// Event is a warning event related to an object.
+type Event struct {
+	Type          string     `json:"type,omitempty"`
+	Reason        string     `json:"reason,omitempty"`
+	Message       string     `json:"message"`
+	Count         int32      `json:"count,omitempty"`
+	LastTimestamp *time.Time `json:"lastTimestamp,omitempty"`
+}
+
+// Replicas holds the rollout progress of a workload.
+type Replicas struct {
+	Desired   int32 `json:"desired"`
+	Ready     int32 `json:"ready"`
+	Available int32 `json:"available"`
+	Updated   int32 `json:"updated"`
+}
+
+// Hint is a suspected root cause of an issue and the object it involves.
+type Hint struct {
+	Cause      string `json:"cause"`
+	Object     string `json:"object"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (h Hint) String() string {
+	hint := h.Cause + " [" + h.Object + "]"
+	if h.Suggestion != "" {
+		hint += ": " + h.Suggestion
+	}
+	return hint
+}
+
+// Reference identifies an object the resource depends on, or an object depending on it.
+type Reference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (r Reference) String() string {
+	if r.Namespace == "" {
+		return r.Kind + " " + r.Name
+	}
+	return r.Kind + " " + r.Namespace + "/" + r.Name
+}
+
+// ContainerLogs holds the last log lines of a container, of its previous run when Previous is set.
+type ContainerLogs struct {
+	Container string   `json:"container"`
+	Previous  bool     `json:"previous,omitempty"`
+	Lines     []string `json:"lines"`
+}
+
+func (l ContainerLogs) String() string {
+	title := "container " + l.Container
+	if l.Previous {
+		title += " (previous)"
+	}
+	return title + ":\n" + strings.Join(l.Lines, "\n")
+}
+
+// NodeUsage holds the version of a node and the share of its allocatable resources requested by its pods.
+type NodeUsage struct {
+	KubeletVersion    string `json:"kubeletVersion"`
+	Unschedulable     bool   `json:"unschedulable,omitempty"`
+	CPUAllocatable    string `json:"cpuAllocatable,omitempty"`
+	CPURequested      int    `json:"cpuRequestedPercent"`
+	MemoryAllocatable string `json:"memoryAllocatable,omitempty"`
+	MemoryRequested   int    `json:"memoryRequestedPercent"`
+	EvictedPods       int    `json:"evictedPods,omitempty"`
+}
+
+// Volume holds the storage of a PersistentVolumeClaim or of a PersistentVolume.
+type Volume struct {
+	StorageClass string `json:"storageClass,omitempty"`
+	Capacity     string `json:"capacity,omitempty"`
+	AccessModes  string `json:"accessModes,omitempty"`
+	// Bound is the PersistentVolume of a claim, or the namespace/name of the claim of a PersistentVolume.
+	Bound string `json:"bound,omitempty"`
+}
+
+// Schedule holds the schedule of a CronJob.
+type Schedule struct {
+	Expression       string     `json:"expression"`
+	LastScheduleTime *time.Time `json:"lastScheduleTime,omitempty"`
+	LastSuccessTime  *time.Time `json:"lastSuccessTime,omitempty"`
+	NextScheduleTime *time.Time `json:"nextScheduleTime,omitempty"`
+}
+
+// ResourceHealth is the result of the analysis of one Kubernetes object.
+// Phase, Ready and Status are the short values displayed in tables,
+// Reason and Message explain why the object is not healthy.
+type ResourceHealth struct {
+	Kind       string          `json:"kind"`
+	Name       string          `json:"name"`
+	Namespace  string          `json:"namespace,omitempty"`
+	CreatedAt  *time.Time      `json:"createdAt,omitempty"`
+	Severity   Severity        `json:"severity"`
+	Phase      string          `json:"phase,omitempty"`
+	Ready      string          `json:"ready,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Replicas   *Replicas       `json:"replicas,omitempty"`
+	Schedule   *Schedule       `json:"schedule,omitempty"`
+	Node       *NodeUsage      `json:"node,omitempty"`
+	Volume     *Volume         `json:"volume,omitempty"`
+	Conditions []Condition     `json:"conditions,omitempty"`
+	Events     []Event         `json:"events,omitempty"`
+	Hints      []Hint          `json:"hints,omitempty"`
+	Logs       []ContainerLogs `json:"logs,omitempty"`
+	// Owner is the workload owning a pod, ManagedBy the Kustomization, HelmRelease or Helm release managing it.
+	Owner     *Reference `json:"owner,omitempty"`
+	ManagedBy *Reference `json:"managedBy,omitempty"`
+	// DependsOn lists the sources and dependencies of a GitOps object, or the claims mounted by a pod.
+	DependsOn []Reference `json:"dependsOn,omitempty"`
+	// RootCause is the failing dependency explaining the failure of the resource.
+	RootCause *Reference `json:"rootCause,omitempty"`
+	// Downstream lists the failing resources whose root cause is this resource.
+	Downstream []Reference `json:"downstream,omitempty"`
+}
+
+// Reference returns the reference of the resource.
+func (r ResourceHealth) Reference() Reference {
+	return Reference{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}
+}
+
+// IsIssue reports whether the object needs attention.
+func (r ResourceHealth) IsIssue() bool {
+	return r.Severity > SeverityOK
+}
+
+// Condition returns the condition of the given type, if any.
+func (r ResourceHealth) Condition(conditionType string) (Condition, bool) {
+	for _, condition := range r.Conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return Condition{}, false
+}
+
+// ConditionStatus returns the status of the condition of the given type, or an empty string.
+func (r ResourceHealth) ConditionStatus(conditionType string) string {
+	condition, _ := r.Condition(conditionType)
+	return condition.Status
+}
+
+// EventMessages joins the messages of the events, one per line.
+func (r ResourceHealth) EventMessages() string {
+	messages := make([]string, 0, len(r.Events))
+	for _, event := range r.Events {
+		messages = append(messages, event.Message)
+	}
+	return strings.Join(messages, "\n")
+}
+
+// HintMessages joins the hints, one per line.
+func (r ResourceHealth) HintMessages() string {
+	messages := make([]string, 0, len(r.Hints))
+	for _, hint := range r.Hints {
+		messages = append(messages, hint.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// LogMessages joins the log tails of the containers.
+func (r ResourceHealth) LogMessages() string {
+	messages := make([]string, 0, len(r.Logs))
+	for _, logs := range r.Logs {
+		messages = append(messages, logs.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Issues returns the resources needing attention.
+func Issues(resources []ResourceHealth) []ResourceHealth {
+	issues := make([]ResourceHealth, 0)
+	for _, resource := range resources {
+		if resource.IsIssue() {
+			issues = append(issues, resource)
+		}
+	}
+	return issues
+}
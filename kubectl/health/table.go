@@ -0,0 +1,136 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// Table describes how a kind of resources is displayed as a table.
+type Table struct {
+	Headers []string
+	Row     func(resource ResourceHealth) []string
+}
+
+// Rows renders the resources with the table columns.
+func (t Table) Rows(resources []ResourceHealth) [][]string {
+	rows := make([][]string, 0, len(resources))
+	for _, resource := range resources {
+		rows = append(rows, t.Row(resource))
+	}
+	return rows
+}
+
+// PodTable displays the pod phase, its conditions and the state of its containers.
+var PodTable = Table{
+	Headers: []string{"NAME", "PHASE", "PODREADY", "INIT", "SCHEDULED", "CTRREADY", "REASON", "STATUS"},
+	Row: func(r ResourceHealth) []string {
+		return []string{
+			r.Name,
+			r.Phase,
+			r.ConditionStatus("Ready"),
+			r.ConditionStatus("Initialized"),
+			r.ConditionStatus("PodScheduled"),
+			r.ConditionStatus("ContainersReady"),
+			r.Reason,
+			r.Status,
+		}
+	},
+}
+
+// CustomResourceTable displays the readiness of a custom resource.
+var CustomResourceTable = Table{
+	Headers: []string{"NAME", "STATUS", "READY", "REASON", "MESSAGE"},
+	Row: func(r ResourceHealth) []string {
+		return []string{r.Name, r.Status, r.Ready, r.Reason, r.Message}
+	},
+}
+
+// WorkloadTable displays the rollout progress of a Deployment, StatefulSet or DaemonSet.
+var WorkloadTable = Table{
+	Headers: []string{"NAME", "STATUS", "AVAILABLE", "READY", "UP-TO-DATE", "REASON"},
+	Row: func(r ResourceHealth) []string {
+		if r.Replicas == nil {
+			return []string{r.Name, r.Status, "", "", "", r.Reason}
+		}
+		return []string{
+			r.Name,
+			r.Status,
+			fmt.Sprintf("%d/%d", r.Replicas.Available, r.Replicas.Desired),
+			fmt.Sprintf("%d/%d", r.Replicas.Ready, r.Replicas.Desired),
+			fmt.Sprintf("%d/%d", r.Replicas.Updated, r.Replicas.Desired),
+			r.Reason,
+		}
+	},
+}
+
+// JobTable displays the completions of a Job.
+var JobTable = Table{
+	Headers: []string{"NAME", "STATUS", "COMPLETIONS", "REASON", "MESSAGE"},
+	Row: func(r ResourceHealth) []string {
+		return []string{r.Name, r.Status, r.Ready, r.Reason, r.Message}
+	},
+}
+
+// CronJobTable displays the schedule of a CronJob.
+var CronJobTable = Table{
+	Headers: []string{"NAME", "STATUS", "SCHEDULE", "LAST SCHEDULE", "NEXT SCHEDULE"},
+	Row: func(r ResourceHealth) []string {
+		if r.Schedule == nil {
+			return []string{r.Name, r.Status, "", "", ""}
+		}
+		return []string{r.Name, r.Status, r.Schedule.Expression, formatTime(r.Schedule.LastScheduleTime), formatTime(r.Schedule.NextScheduleTime)}
+	},
+}
+
+// ServiceTable displays the ready endpoints of a Service against the pods matched by its selector.
+var ServiceTable = Table{
+	Headers: []string{"NAME", "TYPE", "ENDPOINTS/PODS", "REASON", "MESSAGE"},
+	Row: func(r ResourceHealth) []string {
+		return []string{r.Name, r.Status, r.Ready, r.Reason, r.Message}
+	},
+}
+
+// IngressTable displays the hosts of an Ingress and its broken backends or TLS secrets.
+var IngressTable = Table{
+	Headers: []string{"NAME", "CLASS", "HOSTS", "STATUS", "REASON", "MESSAGE"},
+	Row: func(r ResourceHealth) []string {
+		return []string{r.Name, r.Phase, r.Ready, r.Status, r.Reason, r.Message}
+	},
+}
+
+// VolumeTable displays the binding and the storage of a PersistentVolumeClaim or a PersistentVolume.
+var VolumeTable = Table{
+	Headers: []string{"NAME", "STATUS", "BOUND", "CAPACITY", "ACCESS MODES", "STORAGECLASS", "REASON", "MESSAGE"},
+	Row: func(r ResourceHealth) []string {
+		if r.Volume == nil {
+			return []string{r.Name, r.Status, "", "", "", "", r.Reason, r.Message}
+		}
+		return []string{r.Name, r.Status, r.Volume.Bound, r.Volume.Capacity, r.Volume.AccessModes, r.Volume.StorageClass, r.Reason, r.Message}
+	},
+}
+
+// NodeTable displays the readiness, version and requested resources of a node.
+var NodeTable = Table{
+	Headers: []string{"NAME", "STATUS", "VERSION", "CPU REQ", "MEM REQ", "EVICTED", "REASON"},
+	Row: func(r ResourceHealth) []string {
+		if r.Node == nil {
+			return []string{r.Name, r.Status, "", "", "", "", r.Reason}
+		}
+		return []string{
+			r.Name,
+			r.Status,
+			r.Node.KubeletVersion,
+			fmt.Sprintf("%d%% of %s", r.Node.CPURequested, r.Node.CPUAllocatable),
+			fmt.Sprintf("%d%% of %s", r.Node.MemoryRequested, r.Node.MemoryAllocatable),
+			fmt.Sprintf("%d", r.Node.EvictedPods),
+			r.Reason,
+		}
+	},
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,268 @@
+package hints
+
+import (
+	"fmt"
+	"kubectl/health"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Rule suggests a root cause for an unhealthy pod from its container states and warning events.
+type Rule struct {
+	Name  string
+	Match func(pod *corev1.Pod, events []corev1.Event) []health.Hint
+}
+
+// Rules is the rule set evaluated for every pod that is not ready.
+var Rules = []Rule{
+	{Name: "CrashLoopBackOff", Match: crashLoopBackOff},
+	{Name: "ImagePull", Match: imagePull},
+	{Name: "OOMKilled", Match: oomKilled},
+	{Name: "CreateContainerConfigError", Match: createContainerConfigError},
+	{Name: "FailedScheduling", Match: failedScheduling},
+	{Name: "FailedMount", Match: failedMount},
+}
+
+// ForPod evaluates every rule and returns the hints found for the pod.
+func ForPod(pod *corev1.Pod, events []corev1.Event) []health.Hint {
+	var podHints []health.Hint
+	for _, rule := range Rules {
+		podHints = append(podHints, rule.Match(pod, events)...)
+	}
+	return podHints
+}
+
+var (
+	missingObjectRegexp = regexp.MustCompile(`(configmap|secret|configmaps|secrets) "([^"]+)" not found`)
+	missingKeyRegexp    = regexp.MustCompile(`couldn't find key (\S+) in (ConfigMap|Secret) (\S+)`)
+	volumeRegexp        = regexp.MustCompile(`volume "([^"]+)"`)
+	taintRegexp         = regexp.MustCompile(`untolerated taint\(?s?\)? ?(\{[^}]*\})`)
+	insufficientRegexp  = regexp.MustCompile(`Insufficient ([a-zA-Z0-9./-]*[a-zA-Z0-9])`)
+)
+
+func allContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
+
+func containerObject(pod *corev1.Pod, container string) string {
+	return fmt.Sprintf("Pod/%s container %s", pod.Name, container)
+}
+
+func crashLoopBackOff(pod *corev1.Pod, _ []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, c := range allContainerStatuses(pod) {
+		if c.State.Waiting == nil || c.State.Waiting.Reason != "CrashLoopBackOff" {
+			continue
+		}
+		last := c.LastTerminationState.Terminated
+		hint := health.Hint{
+			Cause:      "the container keeps exiting after start",
+			Object:     containerObject(pod, c.Name),
+			Suggestion: "check the logs of the previous run with kubectl logs --previous",
+		}
+		if last != nil {
+			switch {
+			case last.Reason == "OOMKilled":
+				// Reported by the OOMKilled rule.
+				continue
+			case last.ExitCode == 137:
+				hint.Cause = "the container was killed (exit code 137)"
+				hint.Suggestion = "check the liveness probe and the memory limit"
+			case last.ExitCode == 126 || last.ExitCode == 127:
+				hint.Cause = fmt.Sprintf("the container command cannot be executed (exit code %d)", last.ExitCode)
+				hint.Suggestion = "check the command, args and entrypoint of the image"
+			case last.ExitCode != 0:
+				hint.Cause = fmt.Sprintf("the application exits with code %d (%s)", last.ExitCode, last.Reason)
+			}
+		}
+		found = append(found, hint)
+	}
+	return found
+}
+
+func imagePull(pod *corev1.Pod, _ []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, c := range allContainerStatuses(pod) {
+		if c.State.Waiting == nil {
+			continue
+		}
+		switch c.State.Waiting.Reason {
+		case "ImagePullBackOff", "ErrImagePull", "InvalidImageName":
+		default:
+			continue
+		}
+		message := strings.ToLower(c.State.Waiting.Message)
+		hint := health.Hint{
+			Cause:      "the registry cannot be reached",
+			Object:     "image " + c.Image,
+			Suggestion: "check the registry name and the network access from the nodes",
+		}
+		switch {
+		case c.State.Waiting.Reason == "InvalidImageName":
+			hint.Cause = "the image reference is invalid"
+			hint.Suggestion = "fix the image name of container " + c.Name
+		case strings.Contains(message, "not found") || strings.Contains(message, "manifest unknown"):
+			hint.Cause = "the image or tag does not exist"
+			hint.Suggestion = "check the tag of container " + c.Name
+		case strings.Contains(message, "unauthorized") || strings.Contains(message, "authentication required") ||
+			strings.Contains(message, "denied") || strings.Contains(message, "forbidden"):
+			hint.Cause = "the registry refused the credentials"
+			hint.Suggestion = "check the imagePullSecrets of the pod or its service account " + pod.Spec.ServiceAccountName
+		}
+		found = append(found, hint)
+	}
+	return found
+}
+
+func oomKilled(pod *corev1.Pod, _ []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, c := range allContainerStatuses(pod) {
+		killed := (c.State.Terminated != nil && c.State.Terminated.Reason == "OOMKilled") ||
+			(c.LastTerminationState.Terminated != nil && c.LastTerminationState.Terminated.Reason == "OOMKilled")
+		if !killed {
+			continue
+		}
+		limit := "no memory limit"
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			if container.Name == c.Name {
+				if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+					limit = "memory limit " + memory.String()
+				}
+			}
+		}
+		found = append(found, health.Hint{
+			Cause:      "the container ran out of memory (" + limit + ")",
+			Object:     containerObject(pod, c.Name),
+			Suggestion: "raise the memory limit or reduce the memory usage of the application",
+		})
+	}
+	return found
+}
+
+func createContainerConfigError(pod *corev1.Pod, _ []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, c := range allContainerStatuses(pod) {
+		if c.State.Waiting == nil || c.State.Waiting.Reason != "CreateContainerConfigError" {
+			continue
+		}
+		message := c.State.Waiting.Message
+		hint := health.Hint{
+			Cause:      "the container configuration is invalid: " + message,
+			Object:     containerObject(pod, c.Name),
+			Suggestion: "check the env, envFrom and volume references of the container",
+		}
+		if match := missingObjectRegexp.FindStringSubmatch(message); match != nil {
+			kind := kindName(match[1])
+			hint.Cause = "a referenced " + kind + " does not exist"
+			hint.Object = fmt.Sprintf("%s %s/%s", kind, pod.Namespace, match[2])
+			hint.Suggestion = "create the " + kind + " or fix the reference in container " + c.Name
+		} else if match := missingKeyRegexp.FindStringSubmatch(message); match != nil {
+			hint.Cause = "a referenced key does not exist: " + match[1]
+			hint.Object = match[2] + " " + match[3]
+			hint.Suggestion = "add the key or fix the reference in container " + c.Name
+		}
+		found = append(found, hint)
+	}
+	return found
+}
+
+func failedScheduling(pod *corev1.Pod, events []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, event := range latestEvents(events, "FailedScheduling") {
+		message := event.Message
+		object := "Pod/" + pod.Name
+		for _, match := range insufficientRegexp.FindAllStringSubmatch(message, -1) {
+			found = append(found, health.Hint{
+				Cause:      "no node has enough allocatable " + match[1],
+				Object:     object + " requests",
+				Suggestion: "lower the " + match[1] + " requests or add capacity to the cluster",
+			})
+		}
+		if match := taintRegexp.FindStringSubmatch(message); match != nil {
+			found = append(found, health.Hint{
+				Cause:      "the nodes have a taint the pod does not tolerate " + match[1],
+				Object:     object + " tolerations",
+				Suggestion: "add a toleration or schedule the pod on other nodes",
+			})
+		}
+		if strings.Contains(message, "node affinity") || strings.Contains(message, "node selector") {
+			found = append(found, health.Hint{
+				Cause:      "no node matches the node affinity or node selector",
+				Object:     fmt.Sprintf("%s nodeSelector %v", object, pod.Spec.NodeSelector),
+				Suggestion: "check the node labels against the pod affinity and nodeSelector",
+			})
+		}
+		if strings.Contains(message, "unbound immediate PersistentVolumeClaims") {
+			found = append(found, health.Hint{
+				Cause:      "a PersistentVolumeClaim of the pod is not bound",
+				Object:     object + " volumes",
+				Suggestion: "check the PersistentVolumeClaims and their StorageClass",
+			})
+		}
+		if len(found) == 0 {
+			found = append(found, health.Hint{
+				Cause:      "the pod cannot be scheduled: " + message,
+				Object:     object,
+				Suggestion: "check the scheduling constraints of the pod",
+			})
+		}
+	}
+	return found
+}
+
+func failedMount(pod *corev1.Pod, events []corev1.Event) []health.Hint {
+	var found []health.Hint
+	for _, event := range latestEvents(events, "FailedMount", "FailedAttachVolume") {
+		message := event.Message
+		hint := health.Hint{
+			Cause:      "a volume cannot be mounted",
+			Object:     "Pod/" + pod.Name,
+			Suggestion: "check the volume definition and the storage backend",
+		}
+		if match := volumeRegexp.FindStringSubmatch(message); match != nil {
+			hint.Object = fmt.Sprintf("volume %s of Pod/%s", match[1], pod.Name)
+		}
+		if match := missingObjectRegexp.FindStringSubmatch(message); match != nil {
+			kind := kindName(match[1])
+			hint.Cause = "the " + kind + " mounted as a volume does not exist"
+			hint.Object = fmt.Sprintf("%s %s/%s", kind, pod.Namespace, match[2])
+			hint.Suggestion = "create the " + kind + " or fix the volume reference"
+		} else if event.Reason == "FailedAttachVolume" {
+			hint.Cause = "the volume cannot be attached to the node"
+			hint.Suggestion = "check the VolumeAttachment and the CSI driver"
+		}
+		found = append(found, hint)
+	}
+	return found
+}
+
+// latestEvents returns the latest event of each of the given reasons.
+func latestEvents(events []corev1.Event, reasons ...string) []corev1.Event {
+	latest := make([]corev1.Event, 0)
+	for _, reason := range reasons {
+		var selected *corev1.Event
+		for i := range events {
+			if events[i].Reason != reason {
+				continue
+			}
+			if selected == nil || !events[i].LastTimestamp.Before(&selected.LastTimestamp) {
+				selected = &events[i]
+			}
+		}
+		if selected != nil {
+			latest = append(latest, *selected)
+		}
+	}
+	return latest
+}
+
+func kindName(kind string) string {
+	if strings.HasPrefix(strings.ToLower(kind), "configmap") {
+		return "ConfigMap"
+	}
+	return "Secret"
+}
@@ -0,0 +1,183 @@
+package hints
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func waiting(reason string, message string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:  "app",
+		Image: "registry.example.com/app:1.0",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}},
+	}
+}
+
+func crashing(lastReason string, exitCode int32) corev1.ContainerStatus {
+	status := waiting("CrashLoopBackOff", "back-off restarting failed container")
+	status.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: lastReason, ExitCode: exitCode}
+	return status
+}
+
+func warning(reason string, message string, age time.Duration) corev1.Event {
+	return corev1.Event{
+		Type:          corev1.EventTypeWarning,
+		Reason:        reason,
+		Message:       message,
+		LastTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age)),
+	}
+}
+
+func TestForPod(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []corev1.ContainerStatus
+		limits     corev1.ResourceList
+		events     []corev1.Event
+		causes     []string
+		objects    []string
+		suggestion string
+	}{
+		{
+			name:     "healthy pod",
+			statuses: []corev1.ContainerStatus{{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}},
+		},
+		{
+			name:     "crash loop with exit code",
+			statuses: []corev1.ContainerStatus{crashing("Error", 1)},
+			causes:   []string{"the application exits with code 1 (Error)"},
+			objects:  []string{"Pod/api container app"},
+		},
+		{
+			name:       "crash loop killed",
+			statuses:   []corev1.ContainerStatus{crashing("Error", 137)},
+			causes:     []string{"the container was killed (exit code 137)"},
+			suggestion: "check the liveness probe and the memory limit",
+		},
+		{
+			name:     "crash loop command not found",
+			statuses: []corev1.ContainerStatus{crashing("ContainerCannotRun", 127)},
+			causes:   []string{"the container command cannot be executed (exit code 127)"},
+		},
+		{
+			name:     "crash loop after out of memory",
+			statuses: []corev1.ContainerStatus{crashing("OOMKilled", 137)},
+			limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			causes:   []string{"the container ran out of memory (memory limit 128Mi)"},
+		},
+		{
+			name:     "out of memory without limit",
+			statuses: []corev1.ContainerStatus{{Name: "app", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}}},
+			causes:   []string{"the container ran out of memory (no memory limit)"},
+		},
+		{
+			name:     "image not found",
+			statuses: []corev1.ContainerStatus{waiting("ErrImagePull", "rpc error: manifest unknown")},
+			causes:   []string{"the image or tag does not exist"},
+			objects:  []string{"image registry.example.com/app:1.0"},
+		},
+		{
+			name:     "image pull unauthorized",
+			statuses: []corev1.ContainerStatus{waiting("ImagePullBackOff", "pull access denied, authentication required")},
+			causes:   []string{"the registry refused the credentials"},
+		},
+		{
+			name:     "invalid image name",
+			statuses: []corev1.ContainerStatus{waiting("InvalidImageName", "couldn't parse image reference")},
+			causes:   []string{"the image reference is invalid"},
+		},
+		{
+			name:     "registry unreachable",
+			statuses: []corev1.ContainerStatus{waiting("ImagePullBackOff", "dial tcp: i/o timeout")},
+			causes:   []string{"the registry cannot be reached"},
+		},
+		{
+			name:     "missing config map",
+			statuses: []corev1.ContainerStatus{waiting("CreateContainerConfigError", `configmap "settings" not found`)},
+			causes:   []string{"a referenced ConfigMap does not exist"},
+			objects:  []string{"ConfigMap apps/settings"},
+		},
+		{
+			name:     "missing secret key",
+			statuses: []corev1.ContainerStatus{waiting("CreateContainerConfigError", "couldn't find key password in Secret apps/db")},
+			causes:   []string{"a referenced key does not exist: password"},
+			objects:  []string{"Secret apps/db"},
+		},
+		{
+			name:    "insufficient resources",
+			events:  []corev1.Event{warning("FailedScheduling", "0/4 nodes are available: 2 Insufficient cpu, 1 Insufficient memory, 1 Insufficient nvidia.com/gpu.", 0)},
+			causes:  []string{"no node has enough allocatable cpu", "no node has enough allocatable memory", "no node has enough allocatable nvidia.com/gpu"},
+			objects: []string{"Pod/api requests", "Pod/api requests", "Pod/api requests"},
+		},
+		{
+			name:    "untolerated taint",
+			events:  []corev1.Event{warning("FailedScheduling", "0/1 nodes are available: 1 node(s) had untolerated taint {dedicated: gpu}.", 0)},
+			causes:  []string{"the nodes have a taint the pod does not tolerate {dedicated: gpu}"},
+			objects: []string{"Pod/api tolerations"},
+		},
+		{
+			name:    "node selector",
+			events:  []corev1.Event{warning("FailedScheduling", "0/2 nodes are available: 2 node(s) didn't match Pod's node affinity/selector.", 0)},
+			causes:  []string{"no node matches the node affinity or node selector"},
+			objects: []string{"Pod/api nodeSelector map[disk:ssd]"},
+		},
+		{
+			name:   "unbound claim",
+			events: []corev1.Event{warning("FailedScheduling", "0/2 nodes are available: pod has unbound immediate PersistentVolumeClaims.", 0)},
+			causes: []string{"a PersistentVolumeClaim of the pod is not bound"},
+		},
+		{
+			name: "latest scheduling failure only",
+			events: []corev1.Event{
+				warning("FailedScheduling", "0/2 nodes are available: 2 Insufficient cpu.", time.Hour),
+				warning("FailedScheduling", "0/2 nodes are available: 2 node(s) were unschedulable.", 0),
+			},
+			causes: []string{"the pod cannot be scheduled: 0/2 nodes are available: 2 node(s) were unschedulable."},
+		},
+		{
+			name:    "missing secret volume",
+			events:  []corev1.Event{warning("FailedMount", `MountVolume.SetUp failed for volume "certs" : secret "tls" not found`, 0)},
+			causes:  []string{"the Secret mounted as a volume does not exist"},
+			objects: []string{"Secret apps/tls"},
+		},
+		{
+			name:       "volume attach failure",
+			events:     []corev1.Event{warning("FailedAttachVolume", `AttachVolume.Attach failed for volume "pvc-1" : timed out`, 0)},
+			causes:     []string{"the volume cannot be attached to the node"},
+			objects:    []string{"volume pvc-1 of Pod/api"},
+			suggestion: "check the VolumeAttachment and the CSI driver",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "apps"},
+				Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{"disk": "ssd"},
+					Containers:   []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Limits: tt.limits}}},
+				},
+				Status: corev1.PodStatus{ContainerStatuses: tt.statuses},
+			}
+			found := ForPod(pod, tt.events)
+			var causes, objects []string
+			for _, hint := range found {
+				causes = append(causes, hint.Cause)
+				objects = append(objects, hint.Object)
+			}
+			if !reflect.DeepEqual(causes, tt.causes) {
+				t.Errorf("causes %q, want %q", causes, tt.causes)
+			}
+			if tt.objects != nil && !reflect.DeepEqual(objects, tt.objects) {
+				t.Errorf("objects %q, want %q", objects, tt.objects)
+			}
+			if tt.suggestion != "" && found[0].Suggestion != tt.suggestion {
+				t.Errorf("suggestion %q, want %q", found[0].Suggestion, tt.suggestion)
+			}
+		})
+	}
+}
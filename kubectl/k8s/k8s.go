@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"context"
+	"fmt"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/dynamic"
@@ -9,21 +10,27 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+	"kubectl/health"
+	"kubectl/hints"
 	"kubectl/logger"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 )
 
-func GetKubeConfigPath() string {
-	userHomeDir, err := os.UserHomeDir()
-	logger.ErrHandle(err)
-
-	kubeConfigPath := filepath.Join(userHomeDir, ".kube", "config")
-	return kubeConfigPath
+// GetKubeConfigLoadingRules returns the standard kubeconfig loading rules: every file listed in
+// KUBECONFIG is merged, ~/.kube/config is used otherwise, and an explicit path takes precedence over both.
+func GetKubeConfigLoadingRules(explicitPath string) *clientcmd.ClientConfigLoadingRules {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = explicitPath
+	return loadingRules
 }
 
-func GetKubeConfigFromFile(kubeConfigPath string) *api.Config {
-	config, err := clientcmd.LoadFromFile(kubeConfigPath)
+// GetKubeConfig loads and merges the kubeconfig files selected by the loading rules.
+func GetKubeConfig(loadingRules *clientcmd.ClientConfigLoadingRules) *api.Config {
+	config, err := loadingRules.Load()
 	logger.ErrHandle(err)
 	return config
 }
@@ -33,57 +40,194 @@ func GetKubeContexts(config *api.Config) []string {
 	for kubeContext := range config.Contexts {
 		kubeContexts = append(kubeContexts, kubeContext)
 	}
+	sort.Strings(kubeContexts)
 	return kubeContexts
 }
 
-func SwitchKubeContext(context string, kubeConfigPath string, config *api.Config) {
-	config.CurrentContext = context
-	err := clientcmd.WriteToFile(*config, kubeConfigPath)
+// GetRestConfig builds the client configuration for the given context and namespace.
+// The selection is applied in memory through overrides, the kubeconfig files are left untouched.
+// The in-cluster configuration is used when no kubeconfig file is found.
+func GetRestConfig(loadingRules *clientcmd.ClientConfigLoadingRules, context string, namespace string) *rest.Config {
+	config, err := BuildRestConfig(loadingRules, context, namespace)
 	logger.ErrHandle(err)
+	return config
 }
 
-func SwitchKubeContextNamespace(context string, kubeConfigPath string, namespace string, config *api.Config) {
-	config.Contexts[context].Namespace = namespace
-	err := clientcmd.WriteToFile(*config, kubeConfigPath)
-	logger.ErrHandle(err)
+// BuildRestConfig is the error returning variant of GetRestConfig.
+func BuildRestConfig(loadingRules *clientcmd.ClientConfigLoadingRules, context string, namespace string) (*rest.Config, error) {
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: context,
+		Context:        api.Context{Namespace: namespace},
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// BindContext makes the requests of the clients built from the configuration fail once ctx is done,
+// so that an analysis can be abandoned without leaving requests in flight.
+func BindContext(ctx context.Context, config *rest.Config) {
+	config.Wrap(func(next http.RoundTripper) http.RoundTripper {
+		return &contextRoundTripper{ctx: ctx, next: next}
+	})
+}
+
+type contextRoundTripper struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (rt *contextRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := rt.ctx.Err(); err != nil {
+		return nil, err
+	}
+	// The request context is cancelled with ctx, not when RoundTrip returns: the body is read afterwards.
+	ctx, cancel := context.WithCancel(req.Context())
+	context.AfterFunc(rt.ctx, cancel)
+	return rt.next.RoundTrip(req.WithContext(ctx))
+}
+
+// PersistKubeContext writes the chosen context and namespace to the kubeconfig files.
+// The namespace goes to the file defining the context, the current context to the first
+// file that already sets one, as kubectl does. Each file is locked while it is rewritten
+// and its previous content is kept in a ".bak" file. An empty namespace leaves the namespace
+// of the context unchanged.
+func PersistKubeContext(context string, namespace string, loadingRules *clientcmd.ClientConfigLoadingRules) error {
+	var contextFile, currentContextFile string
+	for _, path := range loadingRules.GetLoadingPrecedence() {
+		config, err := clientcmd.LoadFromFile(path)
+		if err != nil {
+			continue
+		}
+		if _, ok := config.Contexts[context]; ok && contextFile == "" {
+			contextFile = path
+		}
+		if config.CurrentContext != "" && currentContextFile == "" {
+			currentContextFile = path
+		}
+	}
+	if contextFile == "" {
+		return fmt.Errorf("context %s not found in kubeconfig files", context)
+	}
+	if currentContextFile == "" {
+		currentContextFile = contextFile
+	}
+
+	err := rewriteKubeConfigFile(contextFile, func(config *api.Config) {
+		if namespace != "" {
+			config.Contexts[context].Namespace = namespace
+		}
+		if contextFile == currentContextFile {
+			config.CurrentContext = context
+		}
+	})
+	if err != nil || contextFile == currentContextFile {
+		return err
+	}
+	return rewriteKubeConfigFile(currentContextFile, func(config *api.Config) {
+		config.CurrentContext = context
+	})
+}
+
+// staleLockAge is the age after which a kubeconfig lock left by a crashed process is removed.
+const staleLockAge = time.Minute
+
+// rewriteKubeConfigFile applies mutate to a kubeconfig file. The file is locked with the ".lock" file kubectl uses
+// and replaced atomically by a temporary file of the same directory, so a crash never leaves it half written.
+func rewriteKubeConfigFile(kubeConfigPath string, mutate func(config *api.Config)) error {
+	kubeConfigPath, err := filepath.EvalSymlinks(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockKubeConfigFile(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	info, err := os.Stat(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(kubeConfigPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(kubeConfigPath+".bak", content, 0600); err != nil {
+		return fmt.Errorf("unable to backup %s: %w", kubeConfigPath, err)
+	}
+
+	config, err := clientcmd.Load(content)
+	if err != nil {
+		return err
+	}
+	mutate(config)
+	content, err = clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+	return replaceFile(kubeConfigPath, content, info.Mode().Perm())
 }
 
-func CreateClient(kubeConfigPath string) kubernetes.Interface {
-	var kubeConfig *rest.Config
-	if kubeConfigPath != "" {
-		config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
-		logger.ErrHandle(err)
-		kubeConfig = config
-	} else {
-		config, err := rest.InClusterConfig()
-		logger.ErrHandle(err)
-		kubeConfig = config
+// lockKubeConfigFile creates the lock file of a kubeconfig file, removing it first when it is older than
+// staleLockAge, and returns the function releasing the lock.
+func lockKubeConfigFile(kubeConfigPath string) (func(), error) {
+	lockPath := kubeConfigPath + ".lock"
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			logger.Logger.Warn("Removing a stale kubeconfig lock", "lock", lockPath, "age", time.Since(info.ModTime()).Round(time.Second))
+			os.Remove(lockPath)
+			lock, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL, 0600)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %w", kubeConfigPath, err)
+	}
+	lock.Close()
+	return func() { os.Remove(lockPath) }, nil
+}
+
+// replaceFile writes the content to a temporary file next to the path and renames it over the path.
+func replaceFile(path string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s: %w", path, err)
 	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func CreateClient(kubeConfig *rest.Config) kubernetes.Interface {
 	client, err := kubernetes.NewForConfig(kubeConfig)
 	logger.ErrHandle(err)
 	return client
 }
 
-func CreateDynamicClient(kubeConfigPath string) dynamic.Interface {
-	var kubeConfig *rest.Config
-	if kubeConfigPath != "" {
-		config, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
-		logger.ErrHandle(err)
-		kubeConfig = config
-	} else {
-		config, err := rest.InClusterConfig()
-		logger.ErrHandle(err)
-		kubeConfig = config
-	}
+func CreateDynamicClient(kubeConfig *rest.Config) dynamic.Interface {
 	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
 	logger.ErrHandle(err)
 	return dynamicClient
 }
 
-func GetPodsList(namespace string, kubeClient kubernetes.Interface) *corev1.PodList {
+func GetPodsList(namespace string, kubeClient kubernetes.Interface) (*corev1.PodList, error) {
 	pods, err := kubeClient.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
-	logger.ErrHandle(err)
-	return pods
+	if err != nil {
+		return nil, fmt.Errorf("failed listing pods in %s: %w", namespace, err)
+	}
+	return pods, nil
 }
 
 func GetNamespacesList(kubeClient kubernetes.Interface) *corev1.NamespaceList {
@@ -92,99 +236,108 @@ func GetNamespacesList(kubeClient kubernetes.Interface) *corev1.NamespaceList {
 	return namespaces
 }
 
-func GetPodStatuses(kubeClient kubernetes.Interface, namespace string, pod *corev1.Pod) (map[string]string, []corev1.Event) {
-	podStatuses := map[string]string{
-		"podReady":             "",
-		"initialized":          "",
-		"scheduled":            "",
-		"containersReady":      "",
-		"reason":               "",
-		"waitingReason":        "",
-		"waitingReasonMessage": "",
+// GetNamespaceNames returns the names of the namespaces matching the label selector.
+func GetNamespaceNames(kubeClient kubernetes.Interface, labelSelector string) ([]string, error) {
+	namespaces, err := kubeClient.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing namespaces: %w", err)
+	}
+	nsNames := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		nsNames = append(nsNames, ns.Name)
+	}
+	return nsNames, nil
+}
+
+// GetPodStatuses evaluates the health of a pod from its conditions and the state of its containers.
+// A pod is an issue until it is ready, including when it is not even scheduled, unless it completed:
+// the pods of finished Jobs are analyzed with their Job. Warning events and hints are only attached to issues.
+func GetPodStatuses(events EventIndex, namespace string, pod *corev1.Pod) health.ResourceHealth {
+	podHealth := health.ResourceHealth{
+		Kind:      "Pod",
+		Name:      pod.Name,
+		Namespace: namespace,
+		CreatedAt: toTime(pod.CreationTimestamp),
+		Phase:     string(pod.Status.Phase),
 	}
-	var podEvents []corev1.Event
 	for _, condition := range pod.Status.Conditions {
-		if condition.Type == corev1.PodReady {
-			podStatuses["podReady"] = string(condition.Status)
-			if podStatuses["podReady"] != "True" {
-				podStatuses["reason"] = condition.Reason
-				podEvents = GetWarningEventsFromResource(kubeClient, "Pod", namespace, pod.Name)
-				for _, c := range pod.Status.ContainerStatuses {
-					if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
-						podStatuses["waitingReason"] = c.State.Waiting.Reason
-						podStatuses["waitingReasonMessage"] = c.State.Waiting.Message
-					}
-					if c.State.Terminated != nil && c.State.Terminated.Reason != "" {
-						podStatuses["waitingReason"] = c.State.Terminated.Reason
-						podStatuses["waitingReasonMessage"] = c.State.Terminated.Message
-					}
-					if c.State.Running != nil {
-						podStatuses["waitingReason"] = "Running"
-					}
-				}
-			}
-		}
-		if condition.Type == corev1.PodInitialized {
-			podStatuses["initialized"] = string(condition.Status)
+		podHealth.Conditions = append(podHealth.Conditions, health.Condition{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: toTime(condition.LastTransitionTime),
+		})
+	}
+	podHealth.Ready = podHealth.ConditionStatus(string(corev1.PodReady))
+	if podHealth.Ready == string(corev1.ConditionTrue) || pod.Status.Phase == corev1.PodSucceeded {
+		return podHealth
+	}
+
+	podHealth.Severity = health.SeverityError
+	if ready, ok := podHealth.Condition(string(corev1.PodReady)); ok {
+		podHealth.Reason = ready.Reason
+	}
+	if scheduled, ok := podHealth.Condition(string(corev1.PodScheduled)); ok && scheduled.Status != string(corev1.ConditionTrue) {
+		podHealth.Reason = scheduled.Reason
+		podHealth.Message = scheduled.Message
+	}
+	podEvents := events.For("Pod", pod.Name)
+	podHealth.Events = ToHealthEvents(podEvents)
+	podHealth.Hints = hints.ForPod(pod, podEvents)
+	for _, c := range pod.Status.ContainerStatuses {
+		if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
+			podHealth.Status = c.State.Waiting.Reason
+			podHealth.Message = c.State.Waiting.Message
 		}
-		if condition.Type == corev1.PodScheduled {
-			podStatuses["scheduled"] = string(condition.Status)
+		if c.State.Terminated != nil && c.State.Terminated.Reason != "" {
+			podHealth.Status = c.State.Terminated.Reason
+			podHealth.Message = c.State.Terminated.Message
 		}
-		if condition.Type == corev1.ContainersReady {
-			podStatuses["containersReady"] = string(condition.Status)
+		if c.State.Running != nil {
+			podHealth.Status = "Running"
 		}
 	}
-	return podStatuses, podEvents
+	return podHealth
 }
 
-func GetPodListErrors(kubeClient kubernetes.Interface, namespace string) ([][]string, []map[string]string) {
-	pods := GetPodsList(namespace, kubeClient)
-
-	podList := make([][]string, 0)
-	podListIssue := make([]map[string]string, 0)
-
-	for _, pod := range pods.Items {
-		podStatuses, podEvents := GetPodStatuses(kubeClient, namespace, &pod)
-		podRow := []string{
-			pod.ObjectMeta.Name,
-			string(pod.Status.Phase),
-			podStatuses["podReady"],
-			podStatuses["initialized"],
-			podStatuses["scheduled"],
-			podStatuses["containersReady"],
-			podStatuses["reason"],
-			podStatuses["waitingReason"],
-		}
-		podList = append(podList, podRow)
-
-		if podRow[2] != "True" {
-			issue := map[string]string{
-				"Name":     pod.ObjectMeta.Name,
-				"Phase":    string(pod.Status.Phase),
-				"PodReady": podStatuses["podReady"],
-				"Reason":   podStatuses["reason"],
-				"Status":   podStatuses["waitingReason"],
-				"Message":  podStatuses["waitingReasonMessage"],
-			}
-			for _, event := range podEvents {
-				issue["EventMessage"] += event.Message + "\n"
-			}
-			podListIssue = append(podListIssue, issue)
+// GetPodListErrors returns the health of the pods of the namespace, as listed by GetPodsList.
+// The pods that are issues are attached to their owning workload and GitOps object,
+// with the log tails of their failing containers.
+func GetPodListErrors(kubeClient kubernetes.Interface, namespace string, pods []corev1.Pod, events EventIndex) []health.ResourceHealth {
+	owners := NewOwnerResolver(kubeClient, namespace)
+	podList := make([]health.ResourceHealth, 0, len(pods))
+	for i := range pods {
+		pod := &pods[i]
+		podHealth := GetPodStatuses(events, namespace, pod)
+		if podHealth.IsIssue() {
+			podHealth.Owner, podHealth.ManagedBy = owners.Resolve(pod)
+			podHealth.Logs = GetPodLogTails(kubeClient, pod)
+			podHealth.DependsOn = ClaimReferences(pod)
 		}
+		podList = append(podList, podHealth)
 	}
-	return podList, podListIssue
+	return podList
 }
 
-func GetWarningEventsFromResource(kubeClient kubernetes.Interface, resourceKind string, namespace string, resourceName string) []corev1.Event {
-	var warningEvents []corev1.Event
-	events, err := kubeClient.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
-	logger.ErrHandle(err)
-	for _, event := range events.Items {
-		if event.InvolvedObject.Kind == resourceKind && event.InvolvedObject.Name == resourceName {
-			if event.Type != "Normal" {
-				warningEvents = append(warningEvents, event)
-			}
-		}
+// ToHealthEvents converts Kubernetes events into the events of the health model.
+func ToHealthEvents(events []corev1.Event) []health.Event {
+	healthEvents := make([]health.Event, 0, len(events))
+	for _, event := range events {
+		healthEvents = append(healthEvents, health.Event{
+			Type:          event.Type,
+			Reason:        event.Reason,
+			Message:       event.Message,
+			Count:         event.Count,
+			LastTimestamp: toTime(event.LastTimestamp),
+		})
+	}
+	return healthEvents
+}
+
+func toTime(t metav1.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
-	return warningEvents
+	return &t.Time
 }
@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"kubectl/health"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodStatuses(t *testing.T) {
+	unschedulable := "0/3 nodes are available: 3 Insufficient cpu."
+	tests := []struct {
+		name     string
+		status   corev1.PodStatus
+		events   []corev1.Event
+		severity health.Severity
+		reason   string
+		hints    int
+	}{
+		{
+			name: "ready",
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+					{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				},
+			},
+			severity: health.SeverityOK,
+		},
+		{
+			name: "not ready",
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+					{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "ContainersNotReady"},
+				},
+			},
+			severity: health.SeverityError,
+			reason:   "ContainersNotReady",
+		},
+		{
+			name: "pending with only PodScheduled false",
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: unschedulable},
+				},
+			},
+			events: []corev1.Event{{
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api"},
+				Type:           corev1.EventTypeWarning,
+				Reason:         "FailedScheduling",
+				Message:        unschedulable,
+			}},
+			severity: health.SeverityError,
+			reason:   "Unschedulable",
+			hints:    1,
+		},
+		{
+			name: "completed job pod",
+			status: corev1.PodStatus{
+				Phase: corev1.PodSucceeded,
+				Conditions: []corev1.PodCondition{
+					{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+					{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "PodCompleted"},
+				},
+			},
+			severity: health.SeverityOK,
+		},
+		{
+			name:     "pending without conditions",
+			status:   corev1.PodStatus{Phase: corev1.PodPending},
+			severity: health.SeverityError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "apps"}, Status: tt.status}
+			got := GetPodStatuses(IndexWarningEvents(tt.events), "apps", pod)
+			if got.Severity != tt.severity || got.Reason != tt.reason {
+				t.Errorf("got %s/%q, want %s/%q", got.Severity, got.Reason, tt.severity, tt.reason)
+			}
+			if len(got.Events) != len(tt.events) {
+				t.Errorf("got %d events, want %d", len(got.Events), len(tt.events))
+			}
+			if len(got.Hints) != tt.hints {
+				t.Errorf("got hints %v, want %d", got.Hints, tt.hints)
+			}
+		})
+	}
+}
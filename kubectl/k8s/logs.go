@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"kubectl/health"
+	"kubectl/logger"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogTailLines is the number of log lines fetched for each failing container, 0 disables the logs.
+var LogTailLines int64 = 20
+
+// GetPodLogTails returns the last lines of the logs of the crashing and failed containers of the pod.
+// The logs of the previous run are fetched as well when the container restarted. Completed pods have no logs tailed.
+func GetPodLogTails(kubeClient kubernetes.Interface, pod *corev1.Pod) []health.ContainerLogs {
+	if LogTailLines <= 0 || pod.Status.Phase == corev1.PodSucceeded {
+		return nil
+	}
+	var logs []health.ContainerLogs
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, c := range statuses {
+		crashing := c.State.Waiting != nil && c.State.Waiting.Reason == "CrashLoopBackOff"
+		failed := c.State.Terminated != nil && c.State.Terminated.ExitCode != 0
+		if !crashing && !failed {
+			continue
+		}
+		for _, previous := range []bool{false, true} {
+			if previous && c.RestartCount == 0 {
+				continue
+			}
+			lines, err := getLogTail(kubeClient, pod, c.Name, previous)
+			if err != nil {
+				logger.Logger.Debug("Unreadable logs", "pod", pod.Name, "container", c.Name, "previous", previous, "err", err)
+				continue
+			}
+			if len(lines) > 0 {
+				logs = append(logs, health.ContainerLogs{Container: c.Name, Previous: previous, Lines: lines})
+			}
+		}
+	}
+	return logs
+}
+
+func getLogTail(kubeClient kubernetes.Interface, pod *corev1.Pod, container string, previous bool) ([]string, error) {
+	tailLines := LogTailLines
+	raw, err := kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &tailLines,
+	}).Do(context.Background()).Raw()
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimRight(string(raw), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
+}
@@ -0,0 +1,241 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"kubectl/health"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetServiceList returns the connectivity of every Service of the namespace: the pods matched by its selector
+// among the pods of the namespace, its ready endpoints and the named target ports of the selected containers.
+// Services without selector have their endpoints managed by hand and only the endpoints are checked.
+func GetServiceList(kubeClient kubernetes.Interface, namespace string, pods []corev1.Pod) ([]health.ResourceHealth, error) {
+	ctx := context.Background()
+	services, err := kubeClient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing services in %s: %w", namespace, err)
+	}
+	if len(services.Items) == 0 {
+		return nil, nil
+	}
+	endpoints, err := kubeClient.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing endpoints in %s: %w", namespace, err)
+	}
+	readyEndpoints := make(map[string]int)
+	for _, endpoint := range endpoints.Items {
+		for _, subset := range endpoint.Subsets {
+			readyEndpoints[endpoint.Name] += len(subset.Addresses)
+		}
+	}
+
+	serviceList := make([]health.ResourceHealth, 0, len(services.Items))
+	for _, service := range services.Items {
+		serviceHealth := health.ResourceHealth{
+			Kind:      "Service",
+			Name:      service.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(service.CreationTimestamp),
+			Status:    string(service.Spec.Type),
+		}
+		if service.Spec.Type == corev1.ServiceTypeExternalName {
+			serviceList = append(serviceList, serviceHealth)
+			continue
+		}
+		var reasons, messages []string
+		report := func(severity health.Severity, reason string, message string) {
+			if severity > serviceHealth.Severity {
+				serviceHealth.Severity = severity
+			}
+			reasons = append(reasons, reason)
+			messages = append(messages, message)
+		}
+
+		var selected []corev1.Pod
+		if len(service.Spec.Selector) > 0 {
+			selector := labels.SelectorFromSet(service.Spec.Selector)
+			for _, pod := range pods {
+				if selector.Matches(labels.Set(pod.Labels)) {
+					selected = append(selected, pod)
+				}
+			}
+			if len(selected) == 0 {
+				report(health.SeverityError, "NoMatchingPods", fmt.Sprintf("selector %s matches no pod", selector))
+			}
+		}
+		serviceHealth.Ready = fmt.Sprintf("%d/%d", readyEndpoints[service.Name], len(selected))
+		if readyEndpoints[service.Name] == 0 && (len(selected) > 0 || len(service.Spec.Selector) == 0) {
+			report(health.SeverityError, "NoReadyEndpoints", "the service has no ready endpoint")
+		}
+		for _, port := range service.Spec.Ports {
+			if len(selected) == 0 {
+				break
+			}
+			if message := checkTargetPort(port, selected); message != "" {
+				report(health.SeverityError, "TargetPortNotExposed", message)
+			}
+		}
+		serviceHealth.Reason = strings.Join(reasons, ",")
+		serviceHealth.Message = strings.Join(messages, "; ")
+		serviceList = append(serviceList, serviceHealth)
+	}
+	return serviceList, nil
+}
+
+// checkTargetPort describes the named target port of a service port that none of the selected containers declares,
+// as such a port cannot be resolved. Numeric target ports are not checked: the container ports are informational
+// and a container may listen on a port it does not declare. The check is skipped when no container declares a port.
+func checkTargetPort(port corev1.ServicePort, pods []corev1.Pod) string {
+	target := port.TargetPort
+	if target.Type != intstr.String {
+		return ""
+	}
+	declared := false
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				declared = true
+				if containerPort.Protocol != "" && port.Protocol != "" && containerPort.Protocol != port.Protocol {
+					continue
+				}
+				if containerPort.Name == target.StrVal {
+					return ""
+				}
+			}
+		}
+	}
+	if !declared {
+		return ""
+	}
+	return fmt.Sprintf("target port %s of port %d is not declared by the selected containers", target.StrVal, port.Port)
+}
+
+// GetIngressList returns the health of every Ingress of the namespace: the Services and ports of its backends
+// and the Secrets of its TLS entries must exist.
+func GetIngressList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	ctx := context.Background()
+	ingresses, err := kubeClient.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing ingresses in %s: %w", namespace, err)
+	}
+	if len(ingresses.Items) == 0 {
+		return nil, nil
+	}
+	services, err := kubeClient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing services in %s: %w", namespace, err)
+	}
+	servicesByName := make(map[string]corev1.Service, len(services.Items))
+	for _, service := range services.Items {
+		servicesByName[service.Name] = service
+	}
+	secretExists := make(map[string]bool)
+
+	ingressList := make([]health.ResourceHealth, 0, len(ingresses.Items))
+	for _, ingress := range ingresses.Items {
+		ingressHealth := health.ResourceHealth{
+			Kind:      "Ingress",
+			Name:      ingress.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(ingress.CreationTimestamp),
+			Status:    "Routed",
+		}
+		if ingress.Spec.IngressClassName != nil {
+			ingressHealth.Phase = *ingress.Spec.IngressClassName
+		}
+		var hosts, reasons, messages []string
+		report := func(reason string, message string) {
+			ingressHealth.Severity = health.SeverityError
+			ingressHealth.Status = "Broken"
+			reasons = append(reasons, reason)
+			messages = append(messages, message)
+		}
+
+		var backends []networkingv1.IngressBackend
+		if ingress.Spec.DefaultBackend != nil {
+			backends = append(backends, *ingress.Spec.DefaultBackend)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host != "" {
+				hosts = append(hosts, rule.Host)
+			}
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				backends = append(backends, path.Backend)
+			}
+		}
+		for _, backend := range backends {
+			if backend.Service == nil {
+				continue
+			}
+			service, ok := servicesByName[backend.Service.Name]
+			if !ok {
+				report("BackendServiceNotFound", fmt.Sprintf("service %s does not exist", backend.Service.Name))
+				continue
+			}
+			if !servicePortExists(service, backend.Service.Port) {
+				report("BackendPortNotFound", fmt.Sprintf("service %s has no port %s", service.Name, backendPortString(backend.Service.Port)))
+			}
+		}
+		for _, tls := range ingress.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+			exists, checked := secretExists[tls.SecretName]
+			if !checked {
+				exists, err = secretExistsIn(kubeClient, namespace, tls.SecretName)
+				if err != nil {
+					return nil, err
+				}
+				secretExists[tls.SecretName] = exists
+			}
+			if !exists {
+				report("TLSSecretNotFound", fmt.Sprintf("TLS secret %s does not exist", tls.SecretName))
+			}
+		}
+		ingressHealth.Ready = strings.Join(hosts, ",")
+		ingressHealth.Reason = strings.Join(reasons, ",")
+		ingressHealth.Message = strings.Join(messages, "; ")
+		ingressList = append(ingressList, ingressHealth)
+	}
+	return ingressList, nil
+}
+
+// secretExistsIn reports whether the Secret exists, assuming it does when the user is not allowed to read it.
+func secretExistsIn(kubeClient kubernetes.Interface, namespace string, name string) (bool, error) {
+	_, err := kubeClient.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	switch {
+	case err == nil, apierrors.IsForbidden(err):
+		return true, nil
+	case apierrors.IsNotFound(err):
+		return false, nil
+	}
+	return false, fmt.Errorf("failed reading secret %s in %s: %w", name, namespace, err)
+}
+
+func servicePortExists(service corev1.Service, port networkingv1.ServiceBackendPort) bool {
+	for _, servicePort := range service.Spec.Ports {
+		if (port.Name != "" && servicePort.Name == port.Name) || (port.Name == "" && servicePort.Port == port.Number) {
+			return true
+		}
+	}
+	return false
+}
+
+func backendPortString(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprintf("%d", port.Number)
+}
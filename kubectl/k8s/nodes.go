@@ -0,0 +1,246 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"kubectl/health"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// maxKubeletSkew is the number of minor versions a kubelet may lag behind the API server.
+	maxKubeletSkew = 3
+	// evictedPodsThreshold is the number of evicted pods from which a node is reported.
+	evictedPodsThreshold = 5
+	// requestedWarningPercent is the share of the allocatable resources requested from which a node is reported.
+	requestedWarningPercent = 90
+)
+
+// nodePressureConditions are the node conditions reporting a resource shortage, with the taint set by the node controller.
+var nodePressureConditions = map[corev1.NodeConditionType]string{
+	corev1.NodeMemoryPressure: "node.kubernetes.io/memory-pressure",
+	corev1.NodeDiskPressure:   "node.kubernetes.io/disk-pressure",
+	corev1.NodePIDPressure:    "node.kubernetes.io/pid-pressure",
+}
+
+// GetNodeList returns the health of every node of the cluster: readiness, resource pressure, cordon,
+// evicted pods, kubelet version skew against the API server and the resources requested by its pods.
+func GetNodeList(kubeClient kubernetes.Interface) ([]health.ResourceHealth, error) {
+	nodes, err := kubeClient.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing nodes: %w", err)
+	}
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing pods: %w", err)
+	}
+	var serverVersion *version.Version
+	if info, err := kubeClient.Discovery().ServerVersion(); err == nil {
+		serverVersion, _ = version.ParseGeneric(info.GitVersion)
+	}
+
+	requests := make(map[string]corev1.ResourceList)
+	evicted := make(map[string]int)
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		if pod.Status.Reason == "Evicted" {
+			evicted[pod.Spec.NodeName]++
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		nodeRequests, ok := requests[pod.Spec.NodeName]
+		if !ok {
+			nodeRequests = corev1.ResourceList{}
+			requests[pod.Spec.NodeName] = nodeRequests
+		}
+		for _, container := range pod.Spec.Containers {
+			for name, quantity := range container.Resources.Requests {
+				total := nodeRequests[name]
+				total.Add(quantity)
+				nodeRequests[name] = total
+			}
+		}
+	}
+
+	nodeList := make([]health.ResourceHealth, 0, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeHealth := health.ResourceHealth{
+			Kind:      "Node",
+			Name:      node.Name,
+			CreatedAt: toTime(node.CreationTimestamp),
+			Status:    "Ready",
+			Node: &health.NodeUsage{
+				KubeletVersion:    node.Status.NodeInfo.KubeletVersion,
+				Unschedulable:     node.Spec.Unschedulable,
+				CPURequested:      requestedPercent(requests[node.Name], node.Status.Allocatable, corev1.ResourceCPU),
+				MemoryRequested:   requestedPercent(requests[node.Name], node.Status.Allocatable, corev1.ResourceMemory),
+				EvictedPods:       evicted[node.Name],
+				CPUAllocatable:    quantityString(node.Status.Allocatable, corev1.ResourceCPU),
+				MemoryAllocatable: quantityString(node.Status.Allocatable, corev1.ResourceMemory),
+			},
+		}
+		var reasons, messages []string
+		report := func(severity health.Severity, reason string, message string) {
+			if severity > nodeHealth.Severity {
+				nodeHealth.Severity = severity
+			}
+			reasons = append(reasons, reason)
+			messages = append(messages, message)
+		}
+
+		for _, condition := range node.Status.Conditions {
+			nodeHealth.Conditions = append(nodeHealth.Conditions, newCondition(condition.Type, condition.Status, condition.Reason, condition.Message, condition.LastTransitionTime))
+			switch {
+			case condition.Type == corev1.NodeReady:
+				nodeHealth.Ready = string(condition.Status)
+				if condition.Status != corev1.ConditionTrue {
+					nodeHealth.Status = "NotReady"
+					report(health.SeverityError, "NotReady", fmt.Sprintf("kubelet not ready: %s", condition.Message))
+				}
+			case nodePressureConditions[condition.Type] != "" && condition.Status == corev1.ConditionTrue:
+				report(health.SeverityError, string(condition.Type), condition.Message)
+			}
+		}
+		if node.Spec.Unschedulable {
+			if nodeHealth.Status == "Ready" {
+				nodeHealth.Status = "SchedulingDisabled"
+			}
+			report(health.SeverityWarning, "Cordoned", "node is cordoned, no new pod is scheduled on it")
+		}
+		if evicted[node.Name] >= evictedPodsThreshold {
+			report(health.SeverityWarning, "EvictedPods", fmt.Sprintf("%d pods were evicted from the node", evicted[node.Name]))
+		}
+		if skew := kubeletSkew(serverVersion, node.Status.NodeInfo.KubeletVersion); skew != "" {
+			report(health.SeverityWarning, "VersionSkew", skew)
+		}
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if percent := requestedPercent(requests[node.Name], node.Status.Allocatable, name); percent >= requestedWarningPercent {
+				report(health.SeverityWarning, "Overcommitted", fmt.Sprintf("%d%% of the allocatable %s is requested", percent, name))
+			}
+		}
+		nodeHealth.Reason = strings.Join(reasons, ",")
+		nodeHealth.Message = strings.Join(messages, "; ")
+		nodeList = append(nodeList, nodeHealth)
+	}
+	return nodeList, nil
+}
+
+// requestedPercent returns the share of the allocatable resource requested by the pods of the node.
+// Init containers and pod overhead are not counted.
+func requestedPercent(requests corev1.ResourceList, allocatable corev1.ResourceList, name corev1.ResourceName) int {
+	capacity, ok := allocatable[name]
+	if !ok || capacity.IsZero() {
+		return 0
+	}
+	requested := requests[name]
+	return int(requested.MilliValue() * 100 / capacity.MilliValue())
+}
+
+func quantityString(resources corev1.ResourceList, name corev1.ResourceName) string {
+	quantity, ok := resources[name]
+	if !ok {
+		return ""
+	}
+	return quantity.String()
+}
+
+// kubeletSkew describes the version skew of a kubelet against the API server, empty when it is supported.
+func kubeletSkew(serverVersion *version.Version, kubeletVersion string) string {
+	if serverVersion == nil {
+		return ""
+	}
+	kubelet, err := version.ParseGeneric(kubeletVersion)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case kubelet.Major() != serverVersion.Major() || kubelet.Minor() > serverVersion.Minor():
+		return fmt.Sprintf("kubelet %s is newer than the API server %s", kubeletVersion, serverVersion)
+	case serverVersion.Minor()-kubelet.Minor() > maxKubeletSkew:
+		return fmt.Sprintf("kubelet %s is more than %d minor versions behind the API server %s", kubeletVersion, maxKubeletSkew, serverVersion)
+	}
+	return ""
+}
+
+// schedulingTaints maps the taints reported by the scheduler to the node problem setting them.
+var schedulingTaints = map[string]string{
+	"node.kubernetes.io/not-ready":     "NotReady",
+	"node.kubernetes.io/unreachable":   "NotReady",
+	"node.kubernetes.io/unschedulable": "Cordoned",
+}
+
+// LinkPendingPods adds to the pods that cannot be scheduled a hint naming the nodes whose conditions explain it.
+// The taints quoted by the FailedScheduling events are matched, and the overcommitted nodes when resources
+// are insufficient. Before the scheduler reported a failure, the node problems preventing scheduling are reported:
+// not ready, cordoned or under pressure, but not the version skew nor the evicted pods.
+func LinkPendingPods(pods []*health.ResourceHealth, nodes []health.ResourceHealth) {
+	nodesByReason := make(map[string][]string)
+	for _, node := range nodes {
+		if node.Reason == "" {
+			continue
+		}
+		for _, reason := range strings.Split(node.Reason, ",") {
+			nodesByReason[reason] = append(nodesByReason[reason], node.Name)
+		}
+	}
+	if len(nodesByReason) == 0 {
+		return
+	}
+	taintReasons := make(map[string]string, len(schedulingTaints)+len(nodePressureConditions))
+	for taint, reason := range schedulingTaints {
+		taintReasons[taint] = reason
+	}
+	for condition, taint := range nodePressureConditions {
+		taintReasons[taint] = string(condition)
+	}
+
+	for _, pod := range pods {
+		if !pod.IsIssue() || pod.Phase != string(corev1.PodPending) || pod.ConditionStatus(string(corev1.PodScheduled)) == string(corev1.ConditionTrue) {
+			continue
+		}
+		reasons := make(map[string]bool)
+		failedScheduling := false
+		for _, event := range pod.Events {
+			if event.Reason != "FailedScheduling" {
+				continue
+			}
+			failedScheduling = true
+			for taint, reason := range taintReasons {
+				if strings.Contains(event.Message, taint) {
+					reasons[reason] = true
+				}
+			}
+			if strings.Contains(event.Message, "Insufficient") {
+				reasons["Overcommitted"] = true
+			}
+		}
+		if !failedScheduling {
+			for _, reason := range taintReasons {
+				reasons[reason] = true
+			}
+		}
+		sortedReasons := make([]string, 0, len(reasons))
+		for reason := range reasons {
+			if len(nodesByReason[reason]) > 0 {
+				sortedReasons = append(sortedReasons, reason)
+			}
+		}
+		sort.Strings(sortedReasons)
+		for _, reason := range sortedReasons {
+			pod.Hints = append(pod.Hints, health.Hint{
+				Cause:      "the pod is pending while nodes report " + reason,
+				Object:     "Node " + strings.Join(nodesByReason[reason], ", "),
+				Suggestion: "check the conditions of the nodes",
+			})
+		}
+	}
+}
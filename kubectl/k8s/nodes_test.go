@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"kubectl/health"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLinkPendingPods(t *testing.T) {
+	nodes := []health.ResourceHealth{
+		{Kind: "Node", Name: "node-a", Reason: "Cordoned"},
+		{Kind: "Node", Name: "node-b", Reason: "MemoryPressure"},
+		{Kind: "Node", Name: "node-c", Reason: "Overcommitted,VersionSkew"},
+		{Kind: "Node", Name: "node-d"},
+	}
+	tests := []struct {
+		name    string
+		message string
+		objects []string
+	}{
+		{
+			name:    "tainted node",
+			message: "0/4 nodes are available: 1 node(s) had untolerated taint {node.kubernetes.io/unschedulable: }, 3 node(s) didn't match Pod's node affinity/selector.",
+			objects: []string{"Node node-a"},
+		},
+		{
+			name:    "pressure taint and insufficient memory",
+			message: "0/4 nodes are available: 1 node(s) had untolerated taint {node.kubernetes.io/memory-pressure: }, 3 Insufficient memory.",
+			objects: []string{"Node node-b", "Node node-c"},
+		},
+		{
+			name:    "scheduling failure unrelated to the nodes",
+			message: "0/4 nodes are available: 4 node(s) didn't match Pod's node affinity/selector.",
+		},
+		{
+			name:    "not scheduled yet",
+			objects: []string{"Node node-a", "Node node-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "apps"},
+				Status: corev1.PodStatus{
+					Phase:      corev1.PodPending,
+					Conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: "Unschedulable"}},
+				},
+			}
+			var events []corev1.Event
+			if tt.message != "" {
+				events = append(events, corev1.Event{
+					InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api"},
+					Type:           corev1.EventTypeWarning,
+					Reason:         "FailedScheduling",
+					Message:        tt.message,
+				})
+			}
+			podHealth := GetPodStatuses(IndexWarningEvents(events), "apps", pod)
+			LinkPendingPods([]*health.ResourceHealth{&podHealth}, nodes)
+			var objects []string
+			for _, hint := range podHealth.Hints {
+				if strings.HasPrefix(hint.Object, "Node ") {
+					objects = append(objects, hint.Object)
+				}
+			}
+			if !reflect.DeepEqual(objects, tt.objects) {
+				t.Errorf("node hints %v, want %v", objects, tt.objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"context"
+	"kubectl/health"
+	"kubectl/logger"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	fluxKustomizationNameLabel      = "kustomize.toolkit.fluxcd.io/name"
+	fluxKustomizationNamespaceLabel = "kustomize.toolkit.fluxcd.io/namespace"
+	fluxHelmReleaseNameLabel        = "helm.toolkit.fluxcd.io/name"
+	fluxHelmReleaseNamespaceLabel   = "helm.toolkit.fluxcd.io/namespace"
+	managedByLabel                  = "app.kubernetes.io/managed-by"
+	instanceLabel                   = "app.kubernetes.io/instance"
+	helmReleaseNameAnnotation       = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation  = "meta.helm.sh/release-namespace"
+)
+
+// OwnerResolver follows the owner references of the pods of a namespace up to their workload.
+// The owners are cached, pods of the same workload share the lookups.
+type OwnerResolver struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	cache      map[string]metav1.Object
+}
+
+func NewOwnerResolver(kubeClient kubernetes.Interface, namespace string) *OwnerResolver {
+	return &OwnerResolver{kubeClient: kubeClient, namespace: namespace, cache: make(map[string]metav1.Object)}
+}
+
+// Resolve returns the workload owning the pod (Deployment, StatefulSet, DaemonSet, CronJob, Job...)
+// and the Kustomization, HelmRelease or Helm release managing it, read from the labels of the workload.
+// Owners that cannot be read end the walk, the last known owner being returned.
+func (r *OwnerResolver) Resolve(pod *corev1.Pod) (*health.Reference, *health.Reference) {
+	var owner *health.Reference
+	var current metav1.Object = pod
+	for {
+		ownerRef := controllerRef(current)
+		if ownerRef == nil {
+			break
+		}
+		owner = &health.Reference{Kind: ownerRef.Kind, Namespace: r.namespace, Name: ownerRef.Name}
+		object, err := r.get(ownerRef.Kind, ownerRef.Name)
+		if err != nil {
+			logger.Logger.Debug("Unreadable owner", "kind", ownerRef.Kind, "name", ownerRef.Name, "namespace", r.namespace, "err", err)
+			break
+		}
+		if object == nil {
+			break
+		}
+		current = object
+	}
+	managedBy := managingObject(current)
+	if managedBy == nil && current != metav1.Object(pod) {
+		managedBy = managingObject(pod)
+	}
+	return owner, managedBy
+}
+
+func controllerRef(object metav1.Object) *metav1.OwnerReference {
+	if ref := metav1.GetControllerOf(object); ref != nil {
+		return ref
+	}
+	if refs := object.GetOwnerReferences(); len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+// get reads an owner of a known kind. A nil object is returned for the other kinds.
+func (r *OwnerResolver) get(kind string, name string) (metav1.Object, error) {
+	key := kind + "/" + name
+	if object, ok := r.cache[key]; ok {
+		return object, nil
+	}
+	ctx := context.Background()
+	var object metav1.Object
+	var err error
+	switch kind {
+	case "ReplicaSet":
+		object, err = r.kubeClient.AppsV1().ReplicaSets(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Deployment":
+		object, err = r.kubeClient.AppsV1().Deployments(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	case "StatefulSet":
+		object, err = r.kubeClient.AppsV1().StatefulSets(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	case "DaemonSet":
+		object, err = r.kubeClient.AppsV1().DaemonSets(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Job":
+		object, err = r.kubeClient.BatchV1().Jobs(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	case "CronJob":
+		object, err = r.kubeClient.BatchV1().CronJobs(r.namespace).Get(ctx, name, metav1.GetOptions{})
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.cache[key] = object
+	return object, nil
+}
+
+// managingObject reads the Flux and Helm labels of an object.
+func managingObject(object metav1.Object) *health.Reference {
+	labels := object.GetLabels()
+	annotations := object.GetAnnotations()
+	namespaceOr := func(namespace string) string {
+		if namespace == "" {
+			return object.GetNamespace()
+		}
+		return namespace
+	}
+	switch {
+	case labels[fluxHelmReleaseNameLabel] != "":
+		return &health.Reference{Kind: "HelmRelease", Namespace: namespaceOr(labels[fluxHelmReleaseNamespaceLabel]), Name: labels[fluxHelmReleaseNameLabel]}
+	case labels[fluxKustomizationNameLabel] != "":
+		return &health.Reference{Kind: "Kustomization", Namespace: namespaceOr(labels[fluxKustomizationNamespaceLabel]), Name: labels[fluxKustomizationNameLabel]}
+	case labels[managedByLabel] == "Helm":
+		name := annotations[helmReleaseNameAnnotation]
+		if name == "" {
+			name = labels[instanceLabel]
+		}
+		if name == "" {
+			return nil
+		}
+		return &health.Reference{Kind: "Helm release", Namespace: namespaceOr(annotations[helmReleaseNamespaceAnnotation]), Name: name}
+	}
+	return nil
+}
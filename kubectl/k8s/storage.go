@@ -0,0 +1,234 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"kubectl/health"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GetPVCList returns the health of every PersistentVolumeClaim of the namespace: pending claims with their
+// provisioning events, missing StorageClasses and the attachment errors of their volumes, as returned by GetAttachErrors.
+// Claims of a WaitForFirstConsumer StorageClass are healthy while they wait for a pod without warning events.
+// The StorageClass checks are skipped when the user is not allowed to read StorageClasses.
+func GetPVCList(kubeClient kubernetes.Interface, events EventIndex, attachErrors map[string]string, namespace string) ([]health.ResourceHealth, error) {
+	ctx := context.Background()
+	claims, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing persistent volume claims in %s: %w", namespace, err)
+	}
+	if len(claims.Items) == 0 {
+		return nil, nil
+	}
+	storageClasses := make(map[string]storageClassInfo)
+
+	claimList := make([]health.ResourceHealth, 0, len(claims.Items))
+	for _, claim := range claims.Items {
+		claimHealth := health.ResourceHealth{
+			Kind:      "PersistentVolumeClaim",
+			Name:      claim.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(claim.CreationTimestamp),
+			Status:    string(claim.Status.Phase),
+			Volume: &health.Volume{
+				Capacity:    quantityString(claim.Status.Capacity, corev1.ResourceStorage),
+				AccessModes: accessModes(claim.Status.AccessModes),
+				Bound:       claim.Spec.VolumeName,
+			},
+		}
+		if claim.Spec.StorageClassName != nil {
+			claimHealth.Volume.StorageClass = *claim.Spec.StorageClassName
+		}
+		var reasons, messages []string
+		report := func(severity health.Severity, reason string, message string) {
+			if severity > claimHealth.Severity {
+				claimHealth.Severity = severity
+			}
+			reasons = append(reasons, reason)
+			messages = append(messages, message)
+		}
+
+		var classInfo storageClassInfo
+		if class := claimHealth.Volume.StorageClass; class != "" {
+			var checked bool
+			classInfo, checked = storageClasses[class]
+			if !checked {
+				classInfo, err = getStorageClassInfo(kubeClient, class)
+				if err != nil {
+					return nil, err
+				}
+				storageClasses[class] = classInfo
+			}
+			if !classInfo.exists {
+				report(health.SeverityError, "StorageClassNotFound", fmt.Sprintf("storage class %s does not exist", class))
+			}
+		}
+		claimEvents := events.For("PersistentVolumeClaim", claim.Name)
+		switch claim.Status.Phase {
+		case corev1.ClaimPending:
+			if len(claimEvents) > 0 {
+				report(health.SeverityError, claimEvents[0].Reason, claimEvents[0].Message)
+			} else if classInfo.waitForFirstConsumer {
+				report(health.SeverityOK, "WaitForFirstConsumer", "the volume is provisioned once a pod uses the claim")
+			} else {
+				report(health.SeverityWarning, "Pending", "the claim is waiting for a volume")
+			}
+		case corev1.ClaimLost:
+			report(health.SeverityError, "ClaimLost", fmt.Sprintf("volume %s does not exist anymore", claim.Spec.VolumeName))
+		}
+		if attachError, ok := attachErrors[claim.Spec.VolumeName]; ok {
+			report(health.SeverityError, "AttachError", attachError)
+		}
+		claimHealth.Reason = strings.Join(reasons, ",")
+		claimHealth.Message = strings.Join(messages, "; ")
+		if claimHealth.IsIssue() {
+			claimHealth.Events = ToHealthEvents(claimEvents)
+		}
+		claimList = append(claimList, claimHealth)
+	}
+	return claimList, nil
+}
+
+// GetPVList returns the health of every PersistentVolume of the cluster: Released volumes still holding the data
+// of a deleted claim, Failed volumes and attachment errors.
+func GetPVList(kubeClient kubernetes.Interface) ([]health.ResourceHealth, error) {
+	volumes, err := kubeClient.CoreV1().PersistentVolumes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing persistent volumes: %w", err)
+	}
+	if len(volumes.Items) == 0 {
+		return nil, nil
+	}
+	attachErrors, err := GetAttachErrors(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+
+	volumeList := make([]health.ResourceHealth, 0, len(volumes.Items))
+	for _, volume := range volumes.Items {
+		volumeHealth := health.ResourceHealth{
+			Kind:      "PersistentVolume",
+			Name:      volume.Name,
+			CreatedAt: toTime(volume.CreationTimestamp),
+			Status:    string(volume.Status.Phase),
+			Reason:    volume.Status.Reason,
+			Message:   volume.Status.Message,
+			Volume: &health.Volume{
+				StorageClass: volume.Spec.StorageClassName,
+				Capacity:     quantityString(volume.Spec.Capacity, corev1.ResourceStorage),
+				AccessModes:  accessModes(volume.Spec.AccessModes),
+			},
+		}
+		if claim := volume.Spec.ClaimRef; claim != nil {
+			volumeHealth.Volume.Bound = claim.Namespace + "/" + claim.Name
+		}
+		switch volume.Status.Phase {
+		case corev1.VolumeFailed:
+			volumeHealth.Severity = health.SeverityError
+			if volumeHealth.Reason == "" {
+				volumeHealth.Reason = "Failed"
+			}
+		case corev1.VolumeReleased:
+			volumeHealth.Severity = health.SeverityWarning
+			volumeHealth.Reason = "Released"
+			volumeHealth.Message = fmt.Sprintf("claim %s was deleted and the volume is kept by its %s reclaim policy", volumeHealth.Volume.Bound, volume.Spec.PersistentVolumeReclaimPolicy)
+		}
+		if attachError, ok := attachErrors[volume.Name]; ok {
+			volumeHealth.Severity = health.SeverityError
+			volumeHealth.Reason = joinNonEmpty(",", volumeHealth.Reason, "AttachError")
+			volumeHealth.Message = joinNonEmpty("; ", volumeHealth.Message, attachError)
+		}
+		volumeList = append(volumeList, volumeHealth)
+	}
+	return volumeList, nil
+}
+
+// ClaimReferences returns the claims mounted by a pod.
+func ClaimReferences(pod *corev1.Pod) []health.Reference {
+	var claims []health.Reference
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claims = append(claims, health.Reference{Kind: "PersistentVolumeClaim", Namespace: pod.Namespace, Name: volume.PersistentVolumeClaim.ClaimName})
+	}
+	return claims
+}
+
+// GetAttachErrors returns the attach and detach errors of the VolumeAttachments by PersistentVolume name.
+// No error is returned when the user is not allowed to list them.
+func GetAttachErrors(kubeClient kubernetes.Interface) (map[string]string, error) {
+	attachments, err := kubeClient.StorageV1().VolumeAttachments().List(context.Background(), metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed listing volume attachments: %w", err)
+	}
+	attachErrors := make(map[string]string)
+	for _, attachment := range attachments.Items {
+		if attachment.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		if message := attachErrorMessage(attachment); message != "" {
+			attachErrors[*attachment.Spec.Source.PersistentVolumeName] = message
+		}
+	}
+	return attachErrors, nil
+}
+
+func attachErrorMessage(attachment storagev1.VolumeAttachment) string {
+	switch {
+	case attachment.Status.AttachError != nil:
+		return fmt.Sprintf("attaching to node %s failed: %s", attachment.Spec.NodeName, attachment.Status.AttachError.Message)
+	case attachment.Status.DetachError != nil:
+		return fmt.Sprintf("detaching from node %s failed: %s", attachment.Spec.NodeName, attachment.Status.DetachError.Message)
+	}
+	return ""
+}
+
+// storageClassInfo is what the claim analysis needs to know about a StorageClass.
+type storageClassInfo struct {
+	exists               bool
+	waitForFirstConsumer bool
+}
+
+// getStorageClassInfo reads a StorageClass, assuming it exists with the Immediate binding mode
+// when the user is not allowed to read it.
+func getStorageClassInfo(kubeClient kubernetes.Interface, name string) (storageClassInfo, error) {
+	class, err := kubeClient.StorageV1().StorageClasses().Get(context.Background(), name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		mode := class.VolumeBindingMode
+		return storageClassInfo{exists: true, waitForFirstConsumer: mode != nil && *mode == storagev1.VolumeBindingWaitForFirstConsumer}, nil
+	case apierrors.IsForbidden(err):
+		return storageClassInfo{exists: true}, nil
+	case apierrors.IsNotFound(err):
+		return storageClassInfo{}, nil
+	}
+	return storageClassInfo{}, fmt.Errorf("failed reading storage class %s: %w", name, err)
+}
+
+func joinNonEmpty(separator string, values ...string) string {
+	nonEmpty := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return strings.Join(nonEmpty, separator)
+}
+
+func accessModes(modes []corev1.PersistentVolumeAccessMode) string {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, string(mode))
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,340 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"kubectl/health"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// cronJobScheduleGrace is the delay after which a CronJob without starting deadline is
+// considered to have missed a schedule.
+const cronJobScheduleGrace = 5 * time.Minute
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func newCondition[T ~string, S ~string](conditionType T, status S, reason string, message string, lastTransitionTime metav1.Time) health.Condition {
+	return health.Condition{
+		Type:               string(conditionType),
+		Status:             string(status),
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: toTime(lastTransitionTime),
+	}
+}
+
+// GetDeploymentList returns the rollout health of every Deployment of the namespace.
+func GetDeploymentList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	deployments, err := kubeClient.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing deployments in %s: %w", namespace, err)
+	}
+
+	deploymentList := make([]health.ResourceHealth, 0, len(deployments.Items))
+	for _, deployment := range deployments.Items {
+		deploymentHealth := health.ResourceHealth{
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(deployment.CreationTimestamp),
+			Status:    "Available",
+			Replicas: &health.Replicas{
+				Desired:   desiredReplicas(deployment.Spec.Replicas),
+				Ready:     deployment.Status.ReadyReplicas,
+				Available: deployment.Status.AvailableReplicas,
+				Updated:   deployment.Status.UpdatedReplicas,
+			},
+		}
+		for _, c := range deployment.Status.Conditions {
+			deploymentHealth.Conditions = append(deploymentHealth.Conditions, newCondition(c.Type, c.Status, c.Reason, c.Message, c.LastTransitionTime))
+		}
+		progressing, _ := deploymentHealth.Condition(string(appsv1.DeploymentProgressing))
+		switch {
+		case progressing.Reason == "ProgressDeadlineExceeded":
+			deploymentHealth.Severity = health.SeverityError
+			deploymentHealth.Status = "Failed"
+			deploymentHealth.Reason = progressing.Reason
+			deploymentHealth.Message = progressing.Message
+		case replicaFailure(&deploymentHealth):
+			failure, _ := deploymentHealth.Condition(string(appsv1.DeploymentReplicaFailure))
+			deploymentHealth.Severity = health.SeverityError
+			deploymentHealth.Status = "Degraded"
+			deploymentHealth.Reason = failure.Reason
+			deploymentHealth.Message = failure.Message
+		case deploymentRollingOut(&deployment, deploymentHealth.Replicas.Desired):
+			// Surge pods are still starting during a rollout, their unready count is not a failure.
+			deploymentHealth.Severity = health.SeverityWarning
+			deploymentHealth.Status = "Progressing"
+			deploymentHealth.Reason = "RolloutInProgress"
+			deploymentHealth.Message = fmt.Sprintf("%d of %d replicas updated", deployment.Status.UpdatedReplicas, deploymentHealth.Replicas.Desired)
+		case deploymentNotHealthy(&deployment):
+			deploymentHealth.Severity = health.SeverityError
+			deploymentHealth.Status = "Degraded"
+			deploymentHealth.Reason = "ReplicasUnavailable"
+			deploymentHealth.Message = fmt.Sprintf("%d of %d replicas available, %d ready", deployment.Status.AvailableReplicas, deploymentHealth.Replicas.Desired, deployment.Status.ReadyReplicas)
+		}
+		deploymentList = append(deploymentList, deploymentHealth)
+	}
+	return deploymentList, nil
+}
+
+func replicaFailure(deploymentHealth *health.ResourceHealth) bool {
+	failure, ok := deploymentHealth.Condition(string(appsv1.DeploymentReplicaFailure))
+	return ok && failure.Status == string(corev1.ConditionTrue)
+}
+
+// deploymentRollingOut reports whether a rollout is in flight: the new generation is not observed yet,
+// some replicas are not updated, or the pods of the previous ReplicaSet are still running.
+func deploymentRollingOut(deployment *appsv1.Deployment, desired int32) bool {
+	return deployment.Status.ObservedGeneration < deployment.Generation ||
+		deployment.Status.UpdatedReplicas < desired ||
+		deployment.Status.Replicas > deployment.Status.UpdatedReplicas
+}
+
+func deploymentNotHealthy(deployment *appsv1.Deployment) bool {
+	// Critères de non-santé :
+	// - Replicas disponibles inférieures aux replicas souhaitées
+	// - Répliques non prêtes supérieures à un seuil configurable (exemple : 0)
+	// - Présence de conditions d'erreurs
+
+	// Récupérer le seuil de répliques non prêtes acceptable
+	maxUnreadyReplicas, err := strconv.Atoi(os.Getenv("DEPLOYMENT_UNREADY_THRESHOLD"))
+	if err != nil || maxUnreadyReplicas < 0 {
+		// Valeur par défaut en cas d'erreur ou de valeur invalide
+		maxUnreadyReplicas = 0
+	}
+
+	availableReplicas := deployment.Status.AvailableReplicas
+	desiredReplicas := desiredReplicas(deployment.Spec.Replicas)
+	unreadyReplicas := deployment.Status.Replicas - deployment.Status.ReadyReplicas
+	conditions := deployment.Status.Conditions
+
+	// Vérifier les conditions
+	for _, condition := range conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse {
+			return true
+		}
+		if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	// Vérifier le nombre de répliques disponibles et non prêtes
+	return availableReplicas < desiredReplicas || unreadyReplicas > int32(maxUnreadyReplicas)
+}
+
+// GetStatefulSetList returns the rollout health of every StatefulSet of the namespace.
+func GetStatefulSetList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	statefulSets, err := kubeClient.AppsV1().StatefulSets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing statefulsets in %s: %w", namespace, err)
+	}
+
+	statefulSetList := make([]health.ResourceHealth, 0, len(statefulSets.Items))
+	for _, statefulSet := range statefulSets.Items {
+		statefulSetHealth := health.ResourceHealth{
+			Kind:      "StatefulSet",
+			Name:      statefulSet.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(statefulSet.CreationTimestamp),
+			Status:    "Available",
+			Replicas: &health.Replicas{
+				Desired:   desiredReplicas(statefulSet.Spec.Replicas),
+				Ready:     statefulSet.Status.ReadyReplicas,
+				Available: statefulSet.Status.AvailableReplicas,
+				Updated:   statefulSet.Status.UpdatedReplicas,
+			},
+		}
+		for _, c := range statefulSet.Status.Conditions {
+			statefulSetHealth.Conditions = append(statefulSetHealth.Conditions, newCondition(c.Type, c.Status, c.Reason, c.Message, c.LastTransitionTime))
+		}
+		replicas := statefulSetHealth.Replicas
+		switch {
+		case statefulSet.Status.ObservedGeneration < statefulSet.Generation ||
+			(statefulSet.Status.UpdateRevision != "" && statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision):
+			statefulSetHealth.Severity = health.SeverityWarning
+			statefulSetHealth.Status = "Progressing"
+			statefulSetHealth.Reason = "RolloutInProgress"
+			statefulSetHealth.Message = fmt.Sprintf("%d of %d replicas updated", replicas.Updated, replicas.Desired)
+		case replicas.Ready < replicas.Desired:
+			statefulSetHealth.Severity = health.SeverityError
+			statefulSetHealth.Status = "Degraded"
+			statefulSetHealth.Reason = "ReplicasNotReady"
+			statefulSetHealth.Message = fmt.Sprintf("%d of %d replicas ready", replicas.Ready, replicas.Desired)
+		}
+		statefulSetList = append(statefulSetList, statefulSetHealth)
+	}
+	return statefulSetList, nil
+}
+
+// GetDaemonSetList returns the rollout health of every DaemonSet of the namespace.
+func GetDaemonSetList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	daemonSets, err := kubeClient.AppsV1().DaemonSets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing daemonsets in %s: %w", namespace, err)
+	}
+
+	daemonSetList := make([]health.ResourceHealth, 0, len(daemonSets.Items))
+	for _, daemonSet := range daemonSets.Items {
+		daemonSetHealth := health.ResourceHealth{
+			Kind:      "DaemonSet",
+			Name:      daemonSet.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(daemonSet.CreationTimestamp),
+			Status:    "Available",
+			Replicas: &health.Replicas{
+				Desired:   daemonSet.Status.DesiredNumberScheduled,
+				Ready:     daemonSet.Status.NumberReady,
+				Available: daemonSet.Status.NumberAvailable,
+				Updated:   daemonSet.Status.UpdatedNumberScheduled,
+			},
+		}
+		for _, c := range daemonSet.Status.Conditions {
+			daemonSetHealth.Conditions = append(daemonSetHealth.Conditions, newCondition(c.Type, c.Status, c.Reason, c.Message, c.LastTransitionTime))
+		}
+		replicas := daemonSetHealth.Replicas
+		switch {
+		case daemonSet.Status.ObservedGeneration < daemonSet.Generation || replicas.Updated < replicas.Desired:
+			daemonSetHealth.Severity = health.SeverityWarning
+			daemonSetHealth.Status = "Progressing"
+			daemonSetHealth.Reason = "RolloutInProgress"
+			daemonSetHealth.Message = fmt.Sprintf("%d of %d pods updated", replicas.Updated, replicas.Desired)
+		case replicas.Available < replicas.Desired:
+			daemonSetHealth.Severity = health.SeverityError
+			daemonSetHealth.Status = "Degraded"
+			daemonSetHealth.Reason = "PodsUnavailable"
+			daemonSetHealth.Message = fmt.Sprintf("%d of %d pods available", replicas.Available, replicas.Desired)
+		case daemonSet.Status.NumberMisscheduled > 0:
+			daemonSetHealth.Severity = health.SeverityWarning
+			daemonSetHealth.Status = "Degraded"
+			daemonSetHealth.Reason = "Misscheduled"
+			daemonSetHealth.Message = fmt.Sprintf("%d pods running on nodes they should not run on", daemonSet.Status.NumberMisscheduled)
+		}
+		daemonSetList = append(daemonSetList, daemonSetHealth)
+	}
+	return daemonSetList, nil
+}
+
+// GetJobList returns the health of every Job of the namespace, failed Jobs being errors.
+func GetJobList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	jobs, err := kubeClient.BatchV1().Jobs(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing jobs in %s: %w", namespace, err)
+	}
+
+	jobList := make([]health.ResourceHealth, 0, len(jobs.Items))
+	for _, job := range jobs.Items {
+		completions := desiredReplicas(job.Spec.Completions)
+		jobHealth := health.ResourceHealth{
+			Kind:      "Job",
+			Name:      job.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(job.CreationTimestamp),
+			Status:    "Running",
+			Ready:     fmt.Sprintf("%d/%d", job.Status.Succeeded, completions),
+		}
+		for _, condition := range job.Status.Conditions {
+			jobHealth.Conditions = append(jobHealth.Conditions, newCondition(condition.Type, condition.Status, condition.Reason, condition.Message, condition.LastTransitionTime))
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				jobHealth.Status = "Complete"
+			case batchv1.JobSuspended:
+				jobHealth.Status = "Suspended"
+			case batchv1.JobFailed:
+				jobHealth.Severity = health.SeverityError
+				jobHealth.Status = "Failed"
+				jobHealth.Reason = condition.Reason
+				jobHealth.Message = condition.Message
+			}
+		}
+		jobList = append(jobList, jobHealth)
+	}
+	return jobList, nil
+}
+
+// GetCronJobList returns the health of every CronJob of the namespace. A CronJob that should
+// have started a Job since its last schedule (plus its starting deadline) missed a schedule.
+func GetCronJobList(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error) {
+	cronJobs, err := kubeClient.BatchV1().CronJobs(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing cronjobs in %s: %w", namespace, err)
+	}
+
+	cronJobList := make([]health.ResourceHealth, 0, len(cronJobs.Items))
+	for _, cronJob := range cronJobs.Items {
+		cronJobHealth := health.ResourceHealth{
+			Kind:      "CronJob",
+			Name:      cronJob.Name,
+			Namespace: namespace,
+			CreatedAt: toTime(cronJob.CreationTimestamp),
+			Status:    "Scheduled",
+			Schedule: &health.Schedule{
+				Expression:       cronJob.Spec.Schedule,
+				LastScheduleTime: toTimePtr(cronJob.Status.LastScheduleTime),
+				LastSuccessTime:  toTimePtr(cronJob.Status.LastSuccessfulTime),
+			},
+		}
+		if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+			cronJobHealth.Status = "Suspended"
+			cronJobList = append(cronJobList, cronJobHealth)
+			continue
+		}
+
+		expression := cronJob.Spec.Schedule
+		if cronJob.Spec.TimeZone != nil {
+			expression = "CRON_TZ=" + *cronJob.Spec.TimeZone + " " + expression
+		}
+		schedule, err := cron.ParseStandard(expression)
+		if err != nil {
+			cronJobHealth.Severity = health.SeverityError
+			cronJobHealth.Status = "Failed"
+			cronJobHealth.Reason = "InvalidSchedule"
+			cronJobHealth.Message = err.Error()
+			cronJobList = append(cronJobList, cronJobHealth)
+			continue
+		}
+
+		lastSchedule := cronJob.CreationTimestamp.Time
+		if cronJob.Status.LastScheduleTime != nil {
+			lastSchedule = cronJob.Status.LastScheduleTime.Time
+		}
+		nextSchedule := schedule.Next(lastSchedule)
+		cronJobHealth.Schedule.NextScheduleTime = &nextSchedule
+
+		deadline := cronJobScheduleGrace
+		if cronJob.Spec.StartingDeadlineSeconds != nil {
+			deadline = time.Duration(*cronJob.Spec.StartingDeadlineSeconds) * time.Second
+		}
+		if time.Now().After(nextSchedule.Add(deadline)) {
+			cronJobHealth.Severity = health.SeverityWarning
+			cronJobHealth.Status = "MissedSchedule"
+			cronJobHealth.Reason = "MissedSchedule"
+			cronJobHealth.Message = fmt.Sprintf("no job started for the schedule of %s", nextSchedule.Format(time.RFC3339))
+		}
+		cronJobList = append(cronJobList, cronJobHealth)
+	}
+	return cronJobList, nil
+}
+
+func toTimePtr(t *metav1.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return toTime(*t)
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"kubectl/health"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetDeploymentList(t *testing.T) {
+	replicas := int32(3)
+	tests := []struct {
+		name       string
+		generation int64
+		status     appsv1.DeploymentStatus
+		want       string
+		severity   health.Severity
+	}{
+		{
+			name:       "available",
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+			want:       "Available",
+			severity:   health.SeverityOK,
+		},
+		{
+			name:       "surge pod starting during a rollout",
+			generation: 2,
+			status: appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3,
+				Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "ReplicaSetUpdated"}},
+			},
+			want:     "Progressing",
+			severity: health.SeverityWarning,
+		},
+		{
+			name:       "old pods terminating after the update",
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+			want:       "Progressing",
+			severity:   health.SeverityWarning,
+		},
+		{
+			name:       "generation not observed",
+			generation: 3,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 3, AvailableReplicas: 3},
+			want:       "Progressing",
+			severity:   health.SeverityWarning,
+		},
+		{
+			name:       "progress deadline exceeded",
+			generation: 2,
+			status: appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1, ReadyReplicas: 3, AvailableReplicas: 3,
+				Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"}},
+			},
+			want:     "Failed",
+			severity: health.SeverityError,
+		},
+		{
+			name:       "replica failure",
+			generation: 2,
+			status: appsv1.DeploymentStatus{
+				ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1, AvailableReplicas: 1,
+				Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentReplicaFailure, Status: corev1.ConditionTrue, Reason: "FailedCreate"}},
+			},
+			want:     "Degraded",
+			severity: health.SeverityError,
+		},
+		{
+			name:       "replicas unavailable",
+			generation: 2,
+			status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, ReadyReplicas: 1, AvailableReplicas: 1},
+			want:       "Degraded",
+			severity:   health.SeverityError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "apps", Generation: tt.generation},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     tt.status,
+			}
+			deployments, err := GetDeploymentList(fake.NewSimpleClientset(deployment), "apps")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := deployments[0]
+			if got.Status != tt.want || got.Severity != tt.severity {
+				t.Errorf("got %s/%s (%s), want %s/%s", got.Status, got.Severity, got.Message, tt.want, tt.severity)
+			}
+		})
+	}
+}
@@ -1,20 +1,25 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
 	"github.com/charmbracelet/huh"
-	appsv1 "k8s.io/api/apps/v1"
+	"github.com/mattn/go-isatty"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 	"kubectl/charm"
 	"kubectl/customresource"
 	"kubectl/k8s"
 	"kubectl/logger"
+	"kubectl/remediate"
+	"kubectl/report"
+	"kubectl/scan"
+	"kubectl/tui"
+	"kubectl/watch"
 	"os"
 	"os/exec"
-	"strconv"
+	"slices"
+	"strings"
+	"time"
 )
 
 func runCmd(command string) (string, error) {
@@ -27,151 +32,181 @@ func runCmd(command string) (string, error) {
 	return string(output), nil
 }
 
-func GetDeploymentList(kubeClient kubernetes.Interface, namespace string) ([][]string, []map[string]string, error) {
-	deployments, err := kubeClient.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
-	logger.ErrHandle(err)
-
-	deploymentList := make([][]string, 0)
-	deploymentListIssue := make([]map[string]string, 0)
-
-	for _, deployment := range deployments.Items {
-		name := deployment.Name
-		availableReplicas := deployment.Status.AvailableReplicas
-		replicas := deployment.Status.Replicas
-		readyReplicas := deployment.Status.ReadyReplicas
-		updatedReplicas := deployment.Status.UpdatedReplicas
-
-		row := []string{
-			name,
-			fmt.Sprintf("%d/%d", availableReplicas, replicas),
-			fmt.Sprintf("%d/%d", readyReplicas, replicas),
-			fmt.Sprintf("%d/%d", updatedReplicas, replicas), // Ajout de la colonne UP-TO-DATE
-		}
-		deploymentList = append(deploymentList, row)
-
-		if deploymentNotHealthy(&deployment) {
-			deploymentListIssue = append(deploymentListIssue, map[string]string{
-				"Name":      name,
-				"Available": fmt.Sprintf("%d/%d", availableReplicas, replicas),
-				"Ready":     fmt.Sprintf("%d/%d", readyReplicas, replicas),
-				"UpToDate":  fmt.Sprintf("%d/%d", updatedReplicas, replicas), // Ajout de la colonne UP-TO-DATE
-			})
+func splitList(list string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return deploymentList, deploymentListIssue, nil
+	return items
 }
 
-func deploymentNotHealthy(deployment *appsv1.Deployment) bool {
-	// Critères de non-santé :
-	// - Replicas disponibles inférieures aux replicas souhaitées
-	// - Répliques non prêtes supérieures à un seuil configurable (exemple : 0)
-	// - Présence de conditions d'erreurs
+// writeReports prints the reports in the machine readable format and exits.
+func writeReports(format string, reports []report.Report, issueDetected bool) {
+	err := report.Write(os.Stdout, format, reports)
+	logger.ErrHandle(err)
+	if issueDetected {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
 
-	// Récupérer le seuil de répliques non prêtes acceptable
-	maxUnreadyReplicas, err := strconv.Atoi(os.Getenv("DEPLOYMENT_UNREADY_THRESHOLD"))
-	if err != nil || maxUnreadyReplicas < 0 {
-		// Valeur par défaut en cas d'erreur ou de valeur invalide
-		maxUnreadyReplicas = 0
+func main() {
+	kubeConfigPath := flag.String("kubeconfig", "", "path to the kubeconfig file, KUBECONFIG files are merged when empty")
+	persistContext := flag.Bool("persist-context", false, "write the chosen context and namespace to the kubeconfig file (a backup is kept)")
+	ctxFlag := flag.String("context", "", "kubernetes context to analyze, skips the forms (the namespace of the context, or default, is analyzed when --namespace is not set)")
+	nsFlag := flag.String("namespace", "", "namespace to analyze, skips the namespace form (the current context is used when --context is not set)")
+	allNamespaces := flag.Bool("all-namespaces", false, "analyze every namespace")
+	nsListFlag := flag.String("namespaces", "", "comma separated list of namespaces to analyze")
+	nsSelector := flag.String("namespace-selector", "", "label selector of the namespaces to analyze")
+	concurrency := flag.Int("concurrency", 4, "number of namespaces analyzed concurrently")
+	fleet := flag.Bool("fleet", false, "analyze every kubeconfig context (or the ones given by --contexts) and print a summary matrix")
+	ctxListFlag := flag.String("contexts", "", "comma separated list of contexts analyzed in fleet mode")
+	clusterConcurrency := flag.Int("cluster-concurrency", 4, "number of clusters analyzed concurrently in fleet mode")
+	clusterTimeout := flag.Duration("cluster-timeout", 2*time.Minute, "maximum duration of the analysis of one cluster in fleet mode")
+	crConfig := flag.String("cr-config", "", "YAML file declaring additional custom resources to analyze")
+	watchMode := flag.Bool("watch", false, "watch the pods, events and custom resources and show a live dashboard")
+	resync := flag.Duration("resync", 5*time.Minute, "resync period of the informers in watch mode")
+	logLines := flag.Int64("log-lines", k8s.LogTailLines, "number of log lines shown for each failing container, 0 disables the logs")
+	certExpiry := flag.Duration("cert-expiry", customresource.ExpiryWindow, "warn on cert-manager certificates expiring within this duration")
+	dryRun := flag.Bool("dry-run", false, "run the remediation actions of the interactive views as server-side dry-runs only")
+	plain := flag.Bool("plain", false, "print the tables instead of the interactive view (always the case when stdout is not a terminal)")
+	output := flag.String("output", "", "output format of the report: "+strings.Join(report.Formats, ", ")+" (terminal tables when empty)")
+	flag.Parse()
+
+	if *output != "" && !slices.Contains(report.Formats, *output) {
+		logger.ErrHandle(fmt.Errorf("unknown output format %q, expected one of %s", *output, strings.Join(report.Formats, ", ")))
+	}
+
+	if *watchMode && (*output != "" || *fleet) {
+		logger.ErrHandle(fmt.Errorf("--watch cannot be combined with --output or --fleet"))
 	}
 
-	availableReplicas := deployment.Status.AvailableReplicas
-	desiredReplicas := deployment.Spec.Replicas
-	unreadyReplicas := deployment.Status.Replicas - deployment.Status.ReadyReplicas
-	conditions := deployment.Status.Conditions
+	k8s.LogTailLines = *logLines
+	customresource.ExpiryWindow = *certExpiry
+
+	crds, err := customresource.LoadRegistry(*crConfig)
+	logger.ErrHandle(err)
 
-	// Vérifier les conditions
-	for _, condition := range conditions {
-		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse {
-			return true
+	multiNamespace := *allNamespaces || *nsListFlag != "" || *nsSelector != ""
+	selection := scan.NamespaceSelection{Names: splitList(*nsListFlag), Selector: *nsSelector}
+
+	loadingRules := k8s.GetKubeConfigLoadingRules(*kubeConfigPath)
+	config := k8s.GetKubeConfig(loadingRules)
+	kubeContextsList := k8s.GetKubeContexts(config)
+
+	if *fleet {
+		fleetContexts := splitList(*ctxListFlag)
+		if len(fleetContexts) == 0 {
+			fleetContexts = kubeContextsList
+		}
+		if *nsFlag != "" {
+			selection.Names = append([]string{*nsFlag}, selection.Names...)
+		}
+		results := scan.AnalyzeFleet(loadingRules, fleetContexts, selection, crds, *concurrency, *clusterConcurrency, *clusterTimeout)
+		if *output != "" {
+			reports := make([]report.Report, 0, len(results))
+			issueDetected := false
+			for _, result := range results {
+				reports = append(reports, result.Report())
+				issueDetected = issueDetected || result.HasIssues()
+			}
+			writeReports(*output, reports, issueDetected)
 		}
-		if condition.Type == appsv1.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue {
-			return true
+		if scan.DisplayFleet(results) {
+			os.Exit(1)
 		}
+		return
 	}
-	// Vérifier le nombre de répliques disponibles et non prêtes
-	return availableReplicas < *desiredReplicas || unreadyReplicas > int32(maxUnreadyReplicas)
-}
 
-func main() {
-	kubeConfigPath := k8s.GetKubeConfigPath()
-	config := k8s.GetKubeConfigFromFile(kubeConfigPath)
-	kubeContextsList := k8s.GetKubeContexts(config)
+	ctxChoice := *ctxFlag
+	if ctxChoice == "" && (*nsFlag != "" || multiNamespace) {
+		ctxChoice = config.CurrentContext
+	}
+	if ctxChoice == "" {
+		contextChoiceForm := charm.GetForm(
+			huh.NewSelect[string]().Title("Kubernetes Context").Description("Please choose a context to operate in").Options(charm.CreateOptionsFromStrings(kubeContextsList)...).Value(&ctxChoice),
+		)
+		err := contextChoiceForm.Run()
+		logger.ErrHandle(err)
+	}
 
-	var ctxChoice string
-	contextChoiceForm := charm.GetForm(
-		huh.NewSelect[string]().Title("Kubernetes Context").Description("Please choose a context to operate in").Options(charm.CreateOptionsFromStrings(kubeContextsList)...).Value(&ctxChoice),
-	)
-	err := contextChoiceForm.Run()
-	logger.ErrHandle(err)
+	restConfig := k8s.GetRestConfig(loadingRules, ctxChoice, "")
+	kubeClient := k8s.CreateClient(restConfig)
+	kubeDynamicClient := k8s.CreateDynamicClient(restConfig)
 
-	k8s.SwitchKubeContext(ctxChoice, kubeConfigPath, config)
+	var namespaces []string
+	if multiNamespace {
+		var err error
+		namespaces, err = selection.Resolve(kubeClient)
+		logger.ErrHandle(err)
+	}
 
-	kubeClient := k8s.CreateClient(kubeConfigPath)
-	kubeDynamicClient := k8s.CreateDynamicClient(kubeConfigPath)
+	nsChoice := *nsFlag
+	if nsChoice == "" && !multiNamespace && *ctxFlag != "" {
+		// The flags skip both forms: the namespace of the context is analyzed.
+		nsChoice = "default"
+		if kubeContext, ok := config.Contexts[ctxChoice]; ok && kubeContext.Namespace != "" {
+			nsChoice = kubeContext.Namespace
+		}
+	}
+	if nsChoice == "" && !multiNamespace {
+		nsList := k8s.GetNamespacesList(kubeClient)
+		nsChoiceForm := charm.GetForm(
+			huh.NewSelect[string]().Title("Kubernetes Namespace").Description("Please choose a namespace to operate in").Options(charm.CreateOptionsFromStrings(func(nsList *corev1.NamespaceList) []string {
+				nsName := make([]string, 0)
+				for _, ns := range nsList.Items {
+					nsName = append(nsName, ns.Name)
+				}
+				return nsName
+			}(nsList))...).Value(&nsChoice),
+		)
+		err := nsChoiceForm.Run()
+		logger.ErrHandle(err)
+	}
 
-	nsList := k8s.GetNamespacesList(kubeClient)
+	if nsChoice != "" && !slices.Contains(namespaces, nsChoice) {
+		namespaces = append([]string{nsChoice}, namespaces...)
+	}
 
-	var nsChoice string
-	nsChoiceForm := charm.GetForm(
-		huh.NewSelect[string]().Title("Kubernetes Namespace").Description("Please choose a namespace to operate in").Options(charm.CreateOptionsFromStrings(func(nsList *corev1.NamespaceList) []string {
-			nsName := make([]string, 0)
-			for _, ns := range nsList.Items {
-				nsName = append(nsName, ns.Name)
-			}
-			return nsName
-		}(nsList))...).Value(&nsChoice),
-	)
-	err = nsChoiceForm.Run()
-	logger.ErrHandle(err)
-	nsChoiceForm.View()
-
-	k8s.SwitchKubeContextNamespace(ctxChoice, kubeConfigPath, nsChoice, config)
-
-	Es := customresource.GetCRD("externalsecret", "", "", "")
-	Ks := customresource.GetCRD("kustomization", "", "", "")
-	Gr := customresource.GetCRD("gitrepository", "", "", "")
-	Hc := customresource.GetCRD("helmrepository", "", "", "")
-	Hr := customresource.GetCRD("helmrelease", "", "", "")
-
-	Es.AnalyzeCRStatus(kubeDynamicClient, kubeClient, nsChoice)
-	Ks.AnalyzeCRStatus(kubeDynamicClient, kubeClient, nsChoice)
-	Gr.AnalyzeCRStatus(kubeDynamicClient, kubeClient, nsChoice)
-	Hc.AnalyzeCRStatus(kubeDynamicClient, kubeClient, nsChoice)
-	Hr.AnalyzeCRStatus(kubeDynamicClient, kubeClient, nsChoice)
-
-	podList, podListIssue := k8s.GetPodListErrors(kubeClient, nsChoice)
-	if podList != nil {
-		charm.CreateObjectArray(podList, []string{"NAME", "PHASE", "PODREADY", "INIT", "SCHEDULED", "CTRREADY", "REASON", "STATUS"})
-	}
-	if len(podListIssue) > 0 {
-		logger.Logger.Error("ISSUE DETECTED", "kind", "Pod")
-		for _, pb := range podListIssue {
-			logger.Logger.Error("Unsynced/NotReady", "name", pb["Name"], "ready", pb["PodReady"], "status", pb["Status"], "errors", pb["EventMessage"])
-			fmt.Println("")
+	if *persistContext {
+		// Several namespaces cannot be persisted, the namespace of the context is kept.
+		persistedNamespace := nsChoice
+		if multiNamespace {
+			persistedNamespace = ""
 		}
-	} else {
-		logger.Logger.Info("All Pods are healthy")
-		fmt.Println("")
-	}
-
-	//deployList, deployListIssue, err := GetDeploymentList(kubeClient, nsChoice)
-	//if deployList != nil {
-	//	charm.CreateObjectArray(deployList, []string{"NAME", "AVAILABLE", "READY", "UP-TO-DATE"})
-	//}
-	//if len(deployListIssue) > 0 {
-	//	logger.Logger.Error("ISSUE DETECTED")
-	//	for _, pb := range deployListIssue {
-	//		logger.Logger.Error("Unsynced/NotReady", "name", pb["Name"], "available", pb["Available"], "ready", pb["Ready"], "uptodate", pb["UpToDate"])
-	//		fmt.Println("")
-	//	}
-	//} else {
-	//	logger.Logger.Info("All Pods are healthy")
-	//	fmt.Println("")
-	//}
-
-	//fmt.Print("pod list")
-	//fmt.Println(podList)
-	//fmt.Print("pod list issue")
-	//fmt.Println(podListIssue)
+		err := k8s.PersistKubeContext(ctxChoice, persistedNamespace, loadingRules)
+		logger.ErrHandle(err)
+	}
+
+	crds, err = customresource.ResolveVersions(kubeClient.Discovery(), crds)
+	logger.ErrHandle(err)
+
+	actions := remediate.NewRemediator(kubeClient, kubeDynamicClient, crds, *dryRun).Actions()
+	if *watchMode {
+		watcher, err := watch.NewWatcher(kubeClient, kubeDynamicClient, namespaces, crds, *resync)
+		logger.ErrHandle(err)
+		logger.ErrHandle(watch.Run(watcher, ctxChoice, namespaces, actions))
+		return
+	}
 
+	results := scan.AddClusterAnalysis(kubeClient, kubeDynamicClient, crds, scan.AnalyzeNamespaces(kubeClient, kubeDynamicClient, namespaces, crds, *concurrency))
+	issueDetected := false
+	for _, result := range results {
+		issueDetected = issueDetected || result.HasIssues()
+	}
+	if *output != "" {
+		writeReports(*output, []report.Report{scan.BuildReport(ctxChoice, results)}, issueDetected)
+	}
+	if !*plain && isatty.IsTerminal(os.Stdout.Fd()) {
+		title := fmt.Sprintf("Context %s • %s", ctxChoice, strings.Join(namespaces, ", "))
+		err := tui.Browse(title, scan.MergeKinds(results), scan.BuildReport(ctxChoice, results).Errors, len(namespaces) > 1, actions)
+		logger.ErrHandle(err)
+	} else {
+		scan.Display(results)
+	}
+	if issueDetected {
+		os.Exit(1)
+	}
 }
@@ -24,6 +24,8 @@ func init() {
 	styles.Values["errors"] = lipgloss.NewStyle().Bold(true)
 	styles.Keys["hint"] = lipgloss.NewStyle().Foreground(lipgloss.Color("204"))
 	styles.Values["hint"] = lipgloss.NewStyle().Bold(true)
+	styles.Keys["cause"] = lipgloss.NewStyle().Foreground(lipgloss.Color("204"))
+	styles.Values["cause"] = lipgloss.NewStyle().Bold(true)
 	styles.Keys["status"] = lipgloss.NewStyle().Foreground(lipgloss.Color("204"))
 	styles.Values["status"] = lipgloss.NewStyle().Bold(true)
 	styles.Keys["object"] = lipgloss.NewStyle().Foreground(lipgloss.Color("204"))
@@ -0,0 +1,220 @@
+package remediate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kubectl/customresource"
+	"kubectl/health"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// RestartedAtAnnotation is the pod template annotation set by kubectl rollout restart.
+	RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	// FluxReconcileAnnotation requests a reconciliation from the Flux controllers.
+	FluxReconcileAnnotation = "reconcile.fluxcd.io/requestedAt"
+	// ForceSyncAnnotation makes the external-secrets operator refresh an ExternalSecret when it changes.
+	ForceSyncAnnotation = "force-sync"
+)
+
+// Action is a remediation that can be run on a selected resource after confirmation.
+type Action struct {
+	Key  string
+	Name string
+	// Applies reports whether the action can be run on the resource.
+	Applies func(resource health.ResourceHealth) bool
+	// Run applies the action and returns what was done. Nothing is persisted when dryRun is set,
+	// the request being validated by the API server only.
+	Run func(resource health.ResourceHealth, dryRun bool) (string, error)
+}
+
+// Remediator runs the actions with the clients of a cluster.
+type Remediator struct {
+	kubeClient        kubernetes.Interface
+	kubeDynamicClient dynamic.Interface
+	crds              []customresource.CustomResourceDefinition
+	dryRunOnly        bool
+}
+
+// NewRemediator creates a remediator. Every action is run as a dry-run when dryRunOnly is set.
+func NewRemediator(kubeClient kubernetes.Interface, kubeDynamicClient dynamic.Interface, crds []customresource.CustomResourceDefinition, dryRunOnly bool) *Remediator {
+	return &Remediator{kubeClient: kubeClient, kubeDynamicClient: kubeDynamicClient, crds: crds, dryRunOnly: dryRunOnly}
+}
+
+// Actions returns the actions in the order they are offered.
+func (r *Remediator) Actions() []Action {
+	return []Action{
+		{Key: "R", Name: "rollout restart the owning Deployment", Applies: isKind("Pod", "Deployment"), Run: r.wrap(r.restartDeployment)},
+		{Key: "X", Name: "delete the pod", Applies: isKind("Pod"), Run: r.wrap(r.deletePod)},
+		{Key: "F", Name: "request a Flux reconcile", Applies: r.isFlux, Run: r.wrap(r.fluxReconcile)},
+		{Key: "P", Name: "suspend", Applies: r.isSuspendable, Run: r.wrap(r.setSuspend(true))},
+		{Key: "U", Name: "resume", Applies: r.isSuspendable, Run: r.wrap(r.setSuspend(false))},
+		{Key: "E", Name: "force-sync the ExternalSecret", Applies: r.isCustomResource("ExternalSecret"), Run: r.wrap(r.forceSync)},
+	}
+}
+
+func (r *Remediator) wrap(run func(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error)) func(health.ResourceHealth, bool) (string, error) {
+	return func(resource health.ResourceHealth, dryRun bool) (string, error) {
+		var dryRunOption []string
+		dryRun = dryRun || r.dryRunOnly
+		if dryRun {
+			dryRunOption = []string{metav1.DryRunAll}
+		}
+		message, err := run(context.Background(), resource, dryRunOption)
+		if err != nil {
+			return "", err
+		}
+		if dryRun {
+			message += " (server dry-run)"
+		}
+		return message, nil
+	}
+}
+
+func isKind(kinds ...string) func(health.ResourceHealth) bool {
+	return func(resource health.ResourceHealth) bool {
+		for _, kind := range kinds {
+			if resource.Kind == kind {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// crd returns the analyzed custom resource of the kind.
+func (r *Remediator) crd(kind string) (customresource.CustomResourceDefinition, bool) {
+	for _, cr := range r.crds {
+		if cr.GetKind() == kind {
+			return cr, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Remediator) isCustomResource(kind string) func(health.ResourceHealth) bool {
+	return func(resource health.ResourceHealth) bool {
+		_, ok := r.crd(kind)
+		return ok && resource.Kind == kind
+	}
+}
+
+func (r *Remediator) isFlux(resource health.ResourceHealth) bool {
+	cr, ok := r.crd(resource.Kind)
+	return ok && strings.HasSuffix(cr.GetGVR().Group, ".toolkit.fluxcd.io")
+}
+
+func (r *Remediator) isSuspendable(resource health.ResourceHealth) bool {
+	return r.isCustomResource("Kustomization")(resource) || r.isCustomResource("HelmRelease")(resource)
+}
+
+// mergePatch nests the value under the path in a JSON merge patch.
+func mergePatch(path []string, value interface{}) ([]byte, error) {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = map[string]interface{}{path[i]: value}
+	}
+	return json.Marshal(value)
+}
+
+// owningDeployment follows the owner references of a pod to its Deployment.
+func (r *Remediator) owningDeployment(ctx context.Context, resource health.ResourceHealth) (string, error) {
+	if resource.Kind == "Deployment" {
+		return resource.Name, nil
+	}
+	pod, err := r.kubeClient.CoreV1().Pods(resource.Namespace).Get(ctx, resource.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind != "ReplicaSet" {
+			continue
+		}
+		replicaSet, err := r.kubeClient.AppsV1().ReplicaSets(resource.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		for _, rsOwner := range replicaSet.OwnerReferences {
+			if rsOwner.Kind == "Deployment" {
+				return rsOwner.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("pod %s/%s is not owned by a Deployment", resource.Namespace, resource.Name)
+}
+
+func (r *Remediator) restartDeployment(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error) {
+	deployment, err := r.owningDeployment(ctx, resource)
+	if err != nil {
+		return "", err
+	}
+	patch, err := mergePatch([]string{"spec", "template", "metadata", "annotations"}, map[string]string{RestartedAtAnnotation: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		return "", err
+	}
+	_, err = r.kubeClient.AppsV1().Deployments(resource.Namespace).Patch(ctx, deployment, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		return "", fmt.Errorf("failed restarting Deployment %s/%s: %w", resource.Namespace, deployment, err)
+	}
+	return fmt.Sprintf("Deployment %s/%s restarted", resource.Namespace, deployment), nil
+}
+
+func (r *Remediator) deletePod(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error) {
+	err := r.kubeClient.CoreV1().Pods(resource.Namespace).Delete(ctx, resource.Name, metav1.DeleteOptions{DryRun: dryRun})
+	if err != nil {
+		return "", fmt.Errorf("failed deleting Pod %s/%s: %w", resource.Namespace, resource.Name, err)
+	}
+	return fmt.Sprintf("Pod %s/%s deleted", resource.Namespace, resource.Name), nil
+}
+
+// patchCustomResource applies a merge patch on the custom resource of the selected row.
+func (r *Remediator) patchCustomResource(ctx context.Context, resource health.ResourceHealth, path []string, value interface{}, dryRun []string) error {
+	cr, ok := r.crd(resource.Kind)
+	if !ok {
+		return fmt.Errorf("%s is not an analyzed custom resource", resource.Kind)
+	}
+	patch, err := mergePatch(path, value)
+	if err != nil {
+		return err
+	}
+	_, err = r.kubeDynamicClient.Resource(cr.GetGVR()).Namespace(resource.Namespace).Patch(ctx, resource.Name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		return fmt.Errorf("failed patching %s %s/%s: %w", resource.Kind, resource.Namespace, resource.Name, err)
+	}
+	return nil
+}
+
+func (r *Remediator) fluxReconcile(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error) {
+	annotations := map[string]string{FluxReconcileAnnotation: time.Now().Format(time.RFC3339Nano)}
+	if err := r.patchCustomResource(ctx, resource, []string{"metadata", "annotations"}, annotations, dryRun); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s/%s reconcile requested", resource.Kind, resource.Namespace, resource.Name), nil
+}
+
+func (r *Remediator) setSuspend(suspend bool) func(context.Context, health.ResourceHealth, []string) (string, error) {
+	return func(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error) {
+		if err := r.patchCustomResource(ctx, resource, []string{"spec", "suspend"}, suspend, dryRun); err != nil {
+			return "", err
+		}
+		if suspend {
+			return fmt.Sprintf("%s %s/%s suspended", resource.Kind, resource.Namespace, resource.Name), nil
+		}
+		return fmt.Sprintf("%s %s/%s resumed", resource.Kind, resource.Namespace, resource.Name), nil
+	}
+}
+
+func (r *Remediator) forceSync(ctx context.Context, resource health.ResourceHealth, dryRun []string) (string, error) {
+	annotations := map[string]string{ForceSyncAnnotation: strconv.FormatInt(time.Now().Unix(), 10)}
+	if err := r.patchCustomResource(ctx, resource, []string{"metadata", "annotations"}, annotations, dryRun); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("ExternalSecret %s/%s force-sync requested", resource.Namespace, resource.Name), nil
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"kubectl/health"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Report gathers the objects analyzed in a cluster. Errors lists the namespaces
+// or the cluster that could not be analyzed.
+type Report struct {
+	Context string                  `json:"context,omitempty"`
+	Objects []health.ResourceHealth `json:"objects"`
+	Errors  []string                `json:"errors,omitempty"`
+}
+
+func (r Report) Unhealthy() []health.ResourceHealth {
+	return health.Issues(r.Objects)
+}
+
+// Formats lists the accepted values of the --output flag besides the default text output.
+var Formats = []string{"json", "yaml", "junit"}
+
+// Write encodes the reports in the given format.
+func Write(w io.Writer, format string, reports []Report) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	case "yaml":
+		content, err := yaml.Marshal(reports)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	case "junit":
+		return writeJUnit(w, reports)
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes one test suite per cluster and one test case per object,
+// unhealthy objects being failed test cases.
+func writeJUnit(w io.Writer, reports []Report) error {
+	suites := junitTestSuites{}
+	for _, r := range reports {
+		suite := junitTestSuite{Name: r.Context}
+		if suite.Name == "" {
+			suite.Name = "kubectl"
+		}
+		for _, obj := range r.Objects {
+			testCase := junitTestCase{
+				Name:      obj.Namespace + "/" + obj.Name,
+				ClassName: obj.Kind,
+			}
+			if obj.IsIssue() {
+				body := obj.Message
+				if obj.Owner != nil {
+					body += "\nowner: " + obj.Owner.String()
+				}
+				if obj.ManagedBy != nil {
+					body += "\nmanaged by: " + obj.ManagedBy.String()
+				}
+				if obj.RootCause != nil {
+					body += "\nroot cause: " + obj.RootCause.String()
+				}
+				for _, downstream := range obj.Downstream {
+					body += "\ndownstream: " + downstream.String()
+				}
+				for _, hint := range obj.Hints {
+					body += "\nhint: " + hint.String()
+				}
+				for _, event := range obj.Events {
+					if event.Reason != "" {
+						body += "\n" + event.Reason + ": " + event.Message
+					} else {
+						body += "\n" + event.Message
+					}
+				}
+				for _, logs := range obj.Logs {
+					body += "\n" + logs.String()
+				}
+				testCase.Failure = &junitMessage{Message: strings.TrimSpace(obj.Status + " " + obj.Reason), Type: obj.Severity.String(), Body: strings.TrimSpace(body)}
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		for _, analysisErr := range r.Errors {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      "analysis",
+				ClassName: "Cluster",
+				Error:     &junitMessage{Message: analysisErr},
+			})
+			suite.Errors++
+		}
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,85 @@
+package scan
+
+import (
+	"kubectl/customresource"
+	"kubectl/health"
+	"kubectl/k8s"
+	"kubectl/logger"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterAnalyzers lists the cluster scoped kinds, in display order.
+var clusterAnalyzers = []struct {
+	kind  string
+	table health.Table
+	list  func(kubeClient kubernetes.Interface) ([]health.ResourceHealth, error)
+}{
+	{"Node", health.NodeTable, k8s.GetNodeList},
+	{"PersistentVolume", health.VolumeTable, k8s.GetPVList},
+}
+
+// AddClusterAnalysis prepends the analysis of the nodes, persistent volumes and cluster scoped custom resources
+// to the namespace results, as a result without namespace, links the pending pods to the node conditions explaining
+// them and propagates the failures of the cluster scoped objects to their dependents.
+// A kind is skipped when the user is not allowed to list it.
+func AddClusterAnalysis(kubeClient kubernetes.Interface, kubeDynamicClient dynamic.Interface, crds []customresource.CustomResourceDefinition, results []NamespaceResult) []NamespaceResult {
+	clusterResult := NamespaceResult{}
+	add := func(kind string, table health.Table, resources []health.ResourceHealth, err error) bool {
+		if apierrors.IsForbidden(err) {
+			logger.Logger.Warn("Skipping the cluster analysis", "kind", kind, "err", err)
+			return true
+		}
+		if err != nil {
+			clusterResult.Err = err
+			return false
+		}
+		if len(resources) > 0 {
+			clusterResult.Kinds = append(clusterResult.Kinds, KindResult{Kind: kind, Table: table, Resources: resources})
+		}
+		return true
+	}
+	for _, analyzer := range clusterAnalyzers {
+		resources, err := analyzer.list(kubeClient)
+		if !add(analyzer.kind, analyzer.table, resources, err) {
+			break
+		}
+		if analyzer.kind == "Node" && err == nil {
+			k8s.LinkPendingPods(podPointers(results), resources)
+		}
+	}
+	for _, cr := range crds {
+		if clusterResult.Err != nil {
+			break
+		}
+		if !cr.IsClusterScoped() {
+			continue
+		}
+		resources, err := cr.GetCRList(kubeDynamicClient, nil, metav1.NamespaceNone)
+		add(cr.GetPrettyName(), health.CustomResourceTable, resources, err)
+	}
+	if clusterResult.Err == nil && len(clusterResult.Kinds) == 0 {
+		return results
+	}
+	results = append([]NamespaceResult{clusterResult}, results...)
+	DependencyGraph(results).Propagate()
+	return results
+}
+
+func podPointers(results []NamespaceResult) []*health.ResourceHealth {
+	pods := make([]*health.ResourceHealth, 0)
+	for i := range results {
+		for j := range results[i].Kinds {
+			if results[i].Kinds[j].Kind != "Pod" {
+				continue
+			}
+			for k := range results[i].Kinds[j].Resources {
+				pods = append(pods, &results[i].Kinds[j].Resources[k])
+			}
+		}
+	}
+	return pods
+}
@@ -0,0 +1,185 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"kubectl/charm"
+	"kubectl/customresource"
+	"kubectl/health"
+	"kubectl/k8s"
+	"kubectl/logger"
+	"kubectl/report"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterResult holds the analysis of one kubeconfig context.
+// Err is set when the cluster could not be reached or did not answer in time.
+type ClusterResult struct {
+	Context    string
+	Namespaces []NamespaceResult
+	Err        error
+}
+
+func (r ClusterResult) HasIssues() bool {
+	if r.Err != nil {
+		return true
+	}
+	for _, ns := range r.Namespaces {
+		if ns.HasIssues() {
+			return true
+		}
+	}
+	return false
+}
+
+// Report converts the cluster result into a report.
+func (r ClusterResult) Report() report.Report {
+	if r.Err != nil {
+		return report.Report{Context: r.Context, Objects: make([]health.ResourceHealth, 0), Errors: []string{r.Err.Error()}}
+	}
+	return BuildReport(r.Context, r.Namespaces)
+}
+
+// AnalyzeCluster builds the clients of a context and analyzes the selected namespaces.
+// The requests of the clients fail once ctx is done.
+func AnalyzeCluster(ctx context.Context, loadingRules *clientcmd.ClientConfigLoadingRules, kubeContext string, selection NamespaceSelection, crds []customresource.CustomResourceDefinition, concurrency int) ClusterResult {
+	result := ClusterResult{Context: kubeContext}
+	restConfig, err := k8s.BuildRestConfig(loadingRules, kubeContext, "")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	k8s.BindContext(ctx, restConfig)
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	kubeDynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	crds, err = customresource.ResolveVersions(kubeClient.Discovery(), crds)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	namespaces, err := selection.Resolve(kubeClient)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Namespaces = AddClusterAnalysis(kubeClient, kubeDynamicClient, crds, AnalyzeNamespaces(kubeClient, kubeDynamicClient, namespaces, crds, concurrency))
+	return result
+}
+
+// AnalyzeFleet analyzes the contexts in parallel, at most clusterConcurrency at a time.
+// A cluster that does not complete within timeout is reported as unreachable: its requests are cancelled
+// and its slot is released once its analysis has returned.
+func AnalyzeFleet(loadingRules *clientcmd.ClientConfigLoadingRules, kubeContexts []string, selection NamespaceSelection, crds []customresource.CustomResourceDefinition, concurrency int, clusterConcurrency int, timeout time.Duration) []ClusterResult {
+	if clusterConcurrency < 1 {
+		clusterConcurrency = 1
+	}
+	results := make([]ClusterResult, len(kubeContexts))
+	sem := make(chan struct{}, clusterConcurrency)
+	var wg sync.WaitGroup
+	for i, kubeContext := range kubeContexts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, kubeContext string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			logger.Logger.Debug("Analyzing cluster", "context", kubeContext)
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			results[i] = AnalyzeCluster(ctx, loadingRules, kubeContext, selection, crds, concurrency)
+			if ctx.Err() != nil {
+				results[i] = ClusterResult{Context: kubeContext, Err: fmt.Errorf("analysis did not complete within %s", timeout)}
+			}
+		}(i, kubeContext)
+	}
+	wg.Wait()
+	return results
+}
+
+// DisplayFleet prints a cluster by kind matrix of the issues found followed by the detail
+// of every issue, and reports whether an issue was detected.
+func DisplayFleet(results []ClusterResult) bool {
+	issueDetected := false
+	kindSet := map[string]bool{}
+	for _, cluster := range results {
+		for _, ns := range cluster.Namespaces {
+			for _, kind := range ns.Kinds {
+				kindSet[kind.Kind] = true
+			}
+		}
+	}
+	kinds := make([]string, 0, len(kindSet))
+	for kind := range kindSet {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	rows := make([][]string, 0, len(results))
+	for _, cluster := range results {
+		row := []string{cluster.Context}
+		if cluster.Err != nil {
+			for range kinds {
+				row = append(row, "unreachable")
+			}
+			rows = append(rows, row)
+			continue
+		}
+		for _, kind := range kinds {
+			total, issues := 0, 0
+			for _, ns := range cluster.Namespaces {
+				for _, k := range ns.Kinds {
+					if k.Kind == kind {
+						total += len(k.Resources)
+						issues += len(k.Issues())
+					}
+				}
+			}
+			row = append(row, fmt.Sprintf("%d/%d", issues, total))
+		}
+		rows = append(rows, row)
+	}
+	fmt.Println(charm.TitleStyle.Render("Fleet summary (issues/objects)"))
+	charm.CreateObjectArray(rows, append([]string{"CLUSTER"}, kinds...))
+
+	for _, cluster := range results {
+		if cluster.Err != nil {
+			issueDetected = true
+			logger.Logger.Error("Cluster unreachable", "context", cluster.Context, "err", cluster.Err)
+			continue
+		}
+		for _, ns := range cluster.Namespaces {
+			if ns.Err != nil {
+				issueDetected = true
+				logger.Logger.Error("Analysis failed", "context", cluster.Context, "scope", ns.Title(), "err", ns.Err)
+				continue
+			}
+			for _, kind := range ns.Kinds {
+				for _, pb := range kind.Issues() {
+					issueDetected = issueDetected || ns.Fails(pb)
+					if pb.RootCause != nil {
+						continue
+					}
+					keyvals := []interface{}{"context", cluster.Context, "namespace", ns.Namespace, "kind", kind.Kind, "name", pb.Name, "status", pb.Status}
+					if owner := ownerLabel(pb); owner != "" {
+						keyvals = append(keyvals, "object", owner)
+					}
+					logger.Logger.Error("Unsynced/NotReady", keyvals...)
+				}
+			}
+		}
+	}
+	return issueDetected
+}
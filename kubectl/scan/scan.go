@@ -0,0 +1,330 @@
+package scan
+
+import (
+	"fmt"
+	"kubectl/charm"
+	"kubectl/customresource"
+	"kubectl/graph"
+	"kubectl/health"
+	"kubectl/k8s"
+	"kubectl/logger"
+	"kubectl/report"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KindResult holds the health of the objects of one kind and the table used to display them.
+type KindResult struct {
+	Kind      string
+	Table     health.Table
+	Resources []health.ResourceHealth
+}
+
+func (k KindResult) Issues() []health.ResourceHealth {
+	return health.Issues(k.Resources)
+}
+
+// NamespaceResult gathers the analysis of every kind of object in a namespace.
+// Err is set when the namespace could not be analyzed.
+type NamespaceResult struct {
+	Namespace string
+	Kinds     []KindResult
+	Err       error
+}
+
+// Title names the scope of the result, the cluster for the result without namespace.
+func (r NamespaceResult) Title() string {
+	if r.Namespace == "" {
+		return "Cluster"
+	}
+	return "Namespace " + r.Namespace
+}
+
+// HasIssues reports whether the result fails the analysis.
+func (r NamespaceResult) HasIssues() bool {
+	if r.Err != nil {
+		return true
+	}
+	for _, kind := range r.Kinds {
+		for _, resource := range kind.Issues() {
+			if r.Fails(resource) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Fails reports whether an issue of the result fails the analysis. The warnings of the cluster result
+// (cordoned or overcommitted nodes, version skew, released volumes) are reported without failing
+// the analysis of the namespaces.
+func (r NamespaceResult) Fails(resource health.ResourceHealth) bool {
+	return resource.IsIssue() && (r.Namespace != "" || resource.Severity == health.SeverityError)
+}
+
+// NamespaceSelection describes which namespaces of a cluster are analyzed.
+// An empty selection matches every namespace.
+type NamespaceSelection struct {
+	Names    []string
+	Selector string
+}
+
+// Resolve returns the namespaces of the cluster matching the selection.
+func (s NamespaceSelection) Resolve(kubeClient kubernetes.Interface) ([]string, error) {
+	if len(s.Names) > 0 {
+		return s.Names, nil
+	}
+	return k8s.GetNamespaceNames(kubeClient, s.Selector)
+}
+
+// BuildReport converts the namespace results of a cluster into a report.
+func BuildReport(kubeContext string, results []NamespaceResult) report.Report {
+	r := report.Report{Context: kubeContext, Objects: make([]health.ResourceHealth, 0)}
+	for _, result := range results {
+		if result.Err != nil {
+			r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", strings.ToLower(result.Title()), result.Err))
+			continue
+		}
+		for _, kind := range result.Kinds {
+			r.Objects = append(r.Objects, kind.Resources...)
+		}
+	}
+	return r
+}
+
+// MergeKinds gathers the resources of each kind across the namespaces, in the order the kinds were analyzed.
+func MergeKinds(results []NamespaceResult) []KindResult {
+	kinds := make([]KindResult, 0)
+	index := make(map[string]int)
+	for _, result := range results {
+		for _, kind := range result.Kinds {
+			i, ok := index[kind.Kind]
+			if !ok {
+				i = len(kinds)
+				index[kind.Kind] = i
+				kinds = append(kinds, KindResult{Kind: kind.Kind, Table: kind.Table})
+			}
+			kinds[i].Resources = append(kinds[i].Resources, kind.Resources...)
+		}
+	}
+	return kinds
+}
+
+// workloadAnalyzers lists the workload kinds analyzed after the pods, in display order.
+var workloadAnalyzers = []struct {
+	kind  string
+	table health.Table
+	list  func(kubeClient kubernetes.Interface, namespace string) ([]health.ResourceHealth, error)
+}{
+	{"Deployment", health.WorkloadTable, k8s.GetDeploymentList},
+	{"StatefulSet", health.WorkloadTable, k8s.GetStatefulSetList},
+	{"DaemonSet", health.WorkloadTable, k8s.GetDaemonSetList},
+	{"Job", health.JobTable, k8s.GetJobList},
+	{"CronJob", health.CronJobTable, k8s.GetCronJobList},
+}
+
+// AnalyzeNamespace runs the custom resource, pod, storage, workload and networking analysis of a single namespace.
+// attachErrors are the VolumeAttachment errors of the cluster, see k8s.GetAttachErrors.
+// A kind is skipped when the user is not allowed to list it, the Services when the pods cannot be listed.
+func AnalyzeNamespace(kubeClient kubernetes.Interface, kubeDynamicClient dynamic.Interface, namespace string, crds []customresource.CustomResourceDefinition, attachErrors map[string]string) NamespaceResult {
+	result := NamespaceResult{Namespace: namespace}
+	skipped := func(kind string, err error) bool {
+		if !apierrors.IsForbidden(err) {
+			return false
+		}
+		logger.Logger.Warn("Skipping the analysis", "kind", kind, "namespace", namespace, "err", err)
+		return true
+	}
+	add := func(kind string, table health.Table, resources []health.ResourceHealth, err error) bool {
+		if skipped(kind, err) {
+			return true
+		}
+		if err != nil {
+			result.Err = err
+			return false
+		}
+		if len(resources) > 0 {
+			result.Kinds = append(result.Kinds, KindResult{Kind: kind, Table: table, Resources: resources})
+		}
+		return true
+	}
+
+	// Without the events, the objects are analyzed from their status only.
+	events, err := k8s.GetWarningEvents(kubeClient, namespace)
+	if err != nil && !skipped("Event", err) {
+		result.Err = err
+		return result
+	}
+	for _, cr := range crds {
+		if cr.IsClusterScoped() {
+			continue
+		}
+		CRList, err := cr.GetCRList(kubeDynamicClient, events, namespace)
+		if !add(cr.GetPrettyName(), health.CustomResourceTable, CRList, err) {
+			return result
+		}
+	}
+	pods, podErr := k8s.GetPodsList(namespace, kubeClient)
+	if podErr != nil && !skipped("Pod", podErr) {
+		result.Err = podErr
+		return result
+	}
+	if podErr == nil {
+		result.Kinds = append(result.Kinds, KindResult{Kind: "Pod", Table: health.PodTable, Resources: k8s.GetPodListErrors(kubeClient, namespace, pods.Items, events)})
+	}
+	claimList, err := k8s.GetPVCList(kubeClient, events, attachErrors, namespace)
+	if !add("PersistentVolumeClaim", health.VolumeTable, claimList, err) {
+		return result
+	}
+
+	for _, workload := range workloadAnalyzers {
+		resources, err := workload.list(kubeClient, namespace)
+		if !add(workload.kind, workload.table, resources, err) {
+			return result
+		}
+	}
+	// The Services are matched against the pods, they are skipped with them.
+	if podErr == nil {
+		services, err := k8s.GetServiceList(kubeClient, namespace, pods.Items)
+		if !add("Service", health.ServiceTable, services, err) {
+			return result
+		}
+	}
+	ingresses, err := k8s.GetIngressList(kubeClient, namespace)
+	add("Ingress", health.IngressTable, ingresses, err)
+	return result
+}
+
+// AnalyzeNamespaces analyzes the namespaces concurrently with at most concurrency workers.
+// Results are returned in the order of the namespaces.
+// The VolumeAttachments of the cluster are listed once and shared by the namespaces.
+func AnalyzeNamespaces(kubeClient kubernetes.Interface, kubeDynamicClient dynamic.Interface, namespaces []string, crds []customresource.CustomResourceDefinition, concurrency int) []NamespaceResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]NamespaceResult, len(namespaces))
+	attachErrors, err := k8s.GetAttachErrors(kubeClient)
+	if err != nil {
+		for i, namespace := range namespaces {
+			results[i] = NamespaceResult{Namespace: namespace, Err: err}
+		}
+		return results
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, namespace := range namespaces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, namespace string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			logger.Logger.Debug("Analyzing namespace", "namespace", namespace)
+			results[i] = AnalyzeNamespace(kubeClient, kubeDynamicClient, namespace, crds, attachErrors)
+		}(i, namespace)
+	}
+	wg.Wait()
+	DependencyGraph(results).Propagate()
+	return results
+}
+
+// groupByOwner orders the issues by owner, keeping the analysis order within an owner
+// and the issues without owner first.
+func groupByOwner(issues []health.ResourceHealth) []health.ResourceHealth {
+	grouped := make([]health.ResourceHealth, len(issues))
+	copy(grouped, issues)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return ownerLabel(grouped[i]) < ownerLabel(grouped[j])
+	})
+	return grouped
+}
+
+// ownerLabel describes the workload and the GitOps object owning a resource.
+func ownerLabel(resource health.ResourceHealth) string {
+	label := ""
+	if resource.Owner != nil {
+		label = resource.Owner.String()
+	}
+	if resource.ManagedBy != nil {
+		label = strings.TrimSpace(label + " managed by " + resource.ManagedBy.String())
+	}
+	return label
+}
+
+// DependencyGraph links the analyzed objects of every namespace to their sources and dependencies.
+func DependencyGraph(results []NamespaceResult) *graph.Graph {
+	resources := make([]*health.ResourceHealth, 0)
+	for i := range results {
+		for j := range results[i].Kinds {
+			for k := range results[i].Kinds[j].Resources {
+				resources = append(resources, &results[i].Kinds[j].Resources[k])
+			}
+		}
+	}
+	return graph.Build(resources)
+}
+
+// Display prints the aggregated report grouped by namespace and reports whether an issue was detected.
+func Display(results []NamespaceResult) bool {
+	issueDetected := false
+	for _, result := range results {
+		fmt.Println(charm.TitleStyle.Render(result.Title()))
+		if result.Err != nil {
+			issueDetected = true
+			logger.Logger.Error("Analysis failed", "scope", result.Title(), "err", result.Err)
+			fmt.Println("")
+			continue
+		}
+		for _, kind := range result.Kinds {
+			if len(kind.Resources) > 0 {
+				charm.CreateObjectArray(kind.Table.Rows(kind.Resources), kind.Table.Headers)
+			}
+			issues := kind.Issues()
+			if len(issues) == 0 {
+				logger.Logger.Info("All objects are healthy", "kind", kind.Kind, "namespace", result.Namespace)
+				continue
+			}
+			for _, pb := range issues {
+				issueDetected = issueDetected || result.Fails(pb)
+			}
+			logger.Logger.Error("ISSUE DETECTED", "kind", kind.Kind, "namespace", result.Namespace)
+			owner := ""
+			for _, pb := range groupByOwner(issues) {
+				if label := ownerLabel(pb); label != owner {
+					owner = label
+					if owner != "" {
+						logger.Logger.Error("Owned by", "object", owner)
+					}
+				}
+				if pb.RootCause != nil {
+					logger.Logger.Warn("Failing because of a dependency", "kind", kind.Kind, "name", pb.Name, "cause", pb.RootCause.String())
+					continue
+				}
+				keyvals := []interface{}{"kind", kind.Kind, "name", pb.Name, "status", pb.Status, "ready", pb.Ready, "errors", pb.EventMessages()}
+				if pb.Reason != "" {
+					keyvals = append(keyvals, "reason", pb.Reason)
+				}
+				if len(pb.Hints) > 0 {
+					keyvals = append(keyvals, "hint", pb.HintMessages())
+				}
+				if len(pb.Logs) > 0 {
+					keyvals = append(keyvals, "logs", pb.LogMessages())
+				}
+				if len(pb.Downstream) > 0 {
+					keyvals = append(keyvals, "downstream", len(pb.Downstream))
+				}
+				logger.Logger.Error("Unsynced/NotReady", keyvals...)
+			}
+		}
+		fmt.Println("")
+	}
+	if dependencies := DependencyGraph(results); dependencies.HasEdges() {
+		fmt.Println(charm.TitleStyle.Render("Dependencies"))
+		fmt.Print(dependencies.Tree())
+	}
+	return issueDetected
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"kubectl/charm"
+	"kubectl/remediate"
+	"kubectl/scan"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// browseModel is the full screen program showing the result of a single analysis.
+type browseModel struct {
+	title   string
+	errors  []string
+	browser *Browser
+}
+
+// Browse shows the kinds in an interactive full screen view until the user quits.
+// The errors of the analysis are listed above the tabs and the actions can be run on the selected rows.
+func Browse(title string, kinds []scan.KindResult, errors []string, namespaceColumn bool, actions []remediate.Action) error {
+	model := &browseModel{title: title, errors: errors, browser: NewBrowser(kinds, namespaceColumn)}
+	model.browser.Actions = actions
+	_, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m *browseModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.browser.SetSize(msg.Width, msg.Height-2-len(m.errors))
+		return m, nil
+	case tea.KeyMsg:
+		if !m.browser.Capturing() && (msg.String() == "q" || msg.String() == "ctrl+c") {
+			return m, tea.Quit
+		}
+	}
+	return m, m.browser.Update(msg)
+}
+
+func (m *browseModel) View() string {
+	sections := []string{charm.TitleStyle.Render(m.title)}
+	for _, err := range m.errors {
+		sections = append(sections, errorStyle.Render(err))
+	}
+	sections = append(sections, m.browser.View(), helpStyle.Render("tab/shift+tab: switch kind • t: dependencies • q: quit"))
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
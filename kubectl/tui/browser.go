@@ -0,0 +1,248 @@
+package tui
+
+import (
+	"fmt"
+	"kubectl/health"
+	"kubectl/remediate"
+	"kubectl/scan"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	activeTabStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("230")).Background(lipgloss.Color("99")).Bold(true).Padding(0, 1)
+	tabStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Padding(0, 1)
+	issueTabStyle  = tabStyle.Copy().Foreground(lipgloss.Color("196"))
+)
+
+// Browser shows one interactive table per kind in tabs. The actions applying to the selected row
+// are run once confirmed, and the dependency tree of the resources can be shown instead of the tables.
+type Browser struct {
+	Tables []*ResourceTable
+	active int
+	width  int
+	height int
+	// tree is the rendered dependency tree, empty when no resource depends on another one.
+	tree       string
+	showTree   bool
+	treeOffset int
+	// Highlight is given to every table, it may be nil.
+	Highlight func(resource health.ResourceHealth) bool
+	Actions   []remediate.Action
+	pending   *pendingAction
+	status    string
+	statusErr bool
+}
+
+// pendingAction is an action waiting for the confirmation of the user.
+type pendingAction struct {
+	action   remediate.Action
+	resource health.ResourceHealth
+}
+
+type actionResultMsg struct {
+	message string
+	err     error
+}
+
+// NewBrowser creates a tab for each kind.
+func NewBrowser(kinds []scan.KindResult, namespaceColumn bool) *Browser {
+	b := &Browser{}
+	for _, kind := range kinds {
+		b.Tables = append(b.Tables, NewResourceTable(kind.Kind, kind.Table, kind.Resources, namespaceColumn))
+	}
+	b.setTree(kinds)
+	return b
+}
+
+func (b *Browser) setTree(kinds []scan.KindResult) {
+	b.tree = ""
+	if dependencies := scan.DependencyGraph([]scan.NamespaceResult{{Kinds: kinds}}); dependencies.HasEdges() {
+		b.tree = strings.TrimRight(dependencies.Tree(), "\n")
+	}
+}
+
+// SetKinds updates the resources of the tabs, adding the tabs of new kinds.
+func (b *Browser) SetKinds(kinds []scan.KindResult, namespaceColumn bool) {
+	for _, kind := range kinds {
+		found := false
+		for _, t := range b.Tables {
+			if t.Kind == kind.Kind {
+				t.SetResources(kind.Resources)
+				found = true
+			}
+		}
+		if !found {
+			t := NewResourceTable(kind.Kind, kind.Table, kind.Resources, namespaceColumn)
+			t.Highlight = b.Highlight
+			t.SetSize(b.width, b.height-3)
+			b.Tables = append(b.Tables, t)
+		}
+	}
+	b.setTree(kinds)
+}
+
+// Active returns the table of the selected tab.
+func (b *Browser) Active() *ResourceTable {
+	if len(b.Tables) == 0 {
+		return nil
+	}
+	return b.Tables[b.active]
+}
+
+// Capturing reports whether the active table is reading keys for its filter.
+func (b *Browser) Capturing() bool {
+	active := b.Active()
+	return active != nil && active.Capturing()
+}
+
+// SetSize sets the space available for the tabs and the active table.
+func (b *Browser) SetSize(width int, height int) {
+	b.width, b.height = width, height
+	for _, t := range b.Tables {
+		t.Highlight = b.Highlight
+		t.SetSize(width, height-3) // tabs line, separator and status line
+	}
+}
+
+// Update switches tabs, asks for the confirmation of the actions and forwards the other keys to the active table.
+func (b *Browser) Update(msg tea.Msg) tea.Cmd {
+	active := b.Active()
+	if active == nil {
+		return nil
+	}
+	if result, ok := msg.(actionResultMsg); ok {
+		b.status, b.statusErr = result.message, result.err != nil
+		if result.err != nil {
+			b.status = result.err.Error()
+		}
+		return nil
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && b.pending != nil {
+		pending := b.pending
+		switch keyMsg.String() {
+		case "y":
+			return b.run(pending, false)
+		case "d":
+			return b.run(pending, true)
+		case "n", "esc":
+			b.pending = nil
+			b.status, b.statusErr = "cancelled", false
+		}
+		return nil
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && b.showTree {
+		switch keyMsg.String() {
+		case "t", "esc":
+			b.showTree = false
+		case "down", "j":
+			b.treeOffset++
+		case "up", "k":
+			b.treeOffset = max(b.treeOffset-1, 0)
+		}
+		return nil
+	}
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && !active.Capturing() {
+		b.status = ""
+		if resource, selected := active.Selected(); selected {
+			for _, action := range b.Actions {
+				if keyMsg.String() == action.Key && action.Applies(resource) {
+					b.pending = &pendingAction{action: action, resource: resource}
+					return nil
+				}
+			}
+		}
+		switch keyMsg.String() {
+		case "tab", "right", "l":
+			b.active = (b.active + 1) % len(b.Tables)
+			return nil
+		case "shift+tab", "left", "h":
+			b.active = (b.active + len(b.Tables) - 1) % len(b.Tables)
+			return nil
+		case "t":
+			b.showTree, b.treeOffset = true, 0
+			return nil
+		}
+	}
+	return active.Update(msg)
+}
+
+// run runs the confirmed action in the background, its result is shown in the status line.
+func (b *Browser) run(pending *pendingAction, dryRun bool) tea.Cmd {
+	b.pending = nil
+	b.status, b.statusErr = fmt.Sprintf("running %s on %s %s/%s...", pending.action.Name, pending.resource.Kind, pending.resource.Namespace, pending.resource.Name), false
+	return func() tea.Msg {
+		message, err := pending.action.Run(pending.resource, dryRun)
+		return actionResultMsg{message: message, err: err}
+	}
+}
+
+// statusLine shows the confirmation prompt, the result of the last action or the available actions.
+func (b *Browser) statusLine() string {
+	if b.pending != nil {
+		return warningStyle.Render(fmt.Sprintf("%s on %s %s/%s? y: apply • d: server dry-run • n: cancel",
+			b.pending.action.Name, b.pending.resource.Kind, b.pending.resource.Namespace, b.pending.resource.Name))
+	}
+	if b.status != "" {
+		if b.statusErr {
+			return errorStyle.Render(b.status)
+		}
+		return b.status
+	}
+	active := b.Active()
+	if active == nil {
+		return ""
+	}
+	resource, selected := active.Selected()
+	if !selected {
+		return ""
+	}
+	actions := make([]string, 0, len(b.Actions))
+	for _, action := range b.Actions {
+		if action.Applies(resource) {
+			actions = append(actions, action.Key+": "+action.Name)
+		}
+	}
+	return helpStyle.Render(strings.Join(actions, " • "))
+}
+
+func (b *Browser) View() string {
+	tabs := make([]string, 0, len(b.Tables))
+	for i, t := range b.Tables {
+		label := fmt.Sprintf("%s %d/%d", t.Kind, t.Issues(), t.Len())
+		switch {
+		case i == b.active:
+			tabs = append(tabs, activeTabStyle.Render(label))
+		case t.Issues() > 0:
+			tabs = append(tabs, issueTabStyle.Render(label))
+		default:
+			tabs = append(tabs, tabStyle.Render(label))
+		}
+	}
+	view := lipgloss.JoinHorizontal(lipgloss.Top, tabs...) + "\n" + b.statusLine()
+	if b.showTree {
+		return view + "\n" + b.treeView()
+	}
+	if active := b.Active(); active != nil {
+		view += "\n" + active.View()
+	}
+	return view
+}
+
+// treeView renders the part of the dependency tree fitting in the view and the key help.
+func (b *Browser) treeView() string {
+	lines := []string{helpStyle.Render("no resource depends on another one")}
+	if b.tree != "" {
+		lines = strings.Split(b.tree, "\n")
+	}
+	height := max(b.height-4, 3) // tabs line, status line, title and help line
+	b.treeOffset = min(b.treeOffset, max(len(lines)-height, 0))
+	lines = lines[b.treeOffset:min(b.treeOffset+height, len(lines))]
+	return lipgloss.JoinVertical(lipgloss.Left,
+		labelStyle.Render("Dependencies"),
+		strings.Join(lines, "\n"),
+		helpStyle.Render("↑/↓: scroll • t/esc: back to the tables"),
+	)
+}
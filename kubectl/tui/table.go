@@ -0,0 +1,409 @@
+package tui
+
+import (
+	"fmt"
+	"kubectl/health"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// maxColumnWidth caps the width of every column but the last one, which takes the remaining space.
+	maxColumnWidth = 32
+	// detailHeight is the height of the detail pane when it is open.
+	detailHeight = 14
+)
+
+var (
+	headerStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99")).Padding(0, 1)
+	cellStyle     = lipgloss.NewStyle().Padding(0, 1)
+	selectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("230")).Background(lipgloss.Color("57"))
+	detailStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("99")).Padding(0, 1)
+	labelStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("99"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	warningStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// ResourceTable is an interactive table of resources of one kind. Rows can be sorted by column,
+// filtered by text or restricted to the issues, and the detail pane shows the selected resource.
+// The failures caused by a failing dependency are collapsed under their root cause until they are shown.
+type ResourceTable struct {
+	Kind            string
+	view            health.Table
+	namespaceColumn bool
+	// Highlight marks the rows that changed recently, it may be nil.
+	Highlight func(resource health.ResourceHealth) bool
+
+	resources  []health.ResourceHealth
+	visible    []health.ResourceHealth
+	table      table.Model
+	filter     textinput.Model
+	filtering  bool
+	issuesOnly bool
+	// showCollapsed shows the resources whose root cause is another resource.
+	showCollapsed bool
+	sortColumn    int
+	sortDesc      bool
+	showDetail    bool
+	// detailOffset is the first line of the detail pane shown.
+	detailOffset int
+	width        int
+	height       int
+}
+
+// NewResourceTable creates the table of a kind. The namespace column is shown when the resources
+// come from several namespaces.
+func NewResourceTable(kind string, view health.Table, resources []health.ResourceHealth, namespaceColumn bool) *ResourceTable {
+	filter := textinput.New()
+	filter.Prompt = "/"
+	filter.Placeholder = "filter"
+	filter.Width = maxColumnWidth
+	t := &ResourceTable{
+		Kind:            kind,
+		view:            view,
+		namespaceColumn: namespaceColumn,
+		filter:          filter,
+		table: table.New(
+			table.WithFocused(true),
+			table.WithStyles(table.Styles{Header: headerStyle, Cell: cellStyle, Selected: selectedStyle}),
+		),
+	}
+	t.SetResources(resources)
+	return t
+}
+
+// headers returns the displayed columns: a severity marker, the namespace when needed and the columns of the kind.
+func (t *ResourceTable) headers() []string {
+	headers := []string{" "}
+	if t.namespaceColumn {
+		headers = append(headers, "NAMESPACE")
+	}
+	return append(headers, t.view.Headers...)
+}
+
+func (t *ResourceTable) row(resource health.ResourceHealth) []string {
+	row := []string{marker(resource, t.Highlight != nil && t.Highlight(resource))}
+	if t.namespaceColumn {
+		row = append(row, resource.Namespace)
+	}
+	return append(row, t.view.Row(resource)...)
+}
+
+// marker is the first column of a row. The bubbles table does not style rows independently,
+// so the severity is shown with a symbol.
+func marker(resource health.ResourceHealth, changed bool) string {
+	if changed {
+		return "●"
+	}
+	if resource.RootCause != nil {
+		return "↳"
+	}
+	switch resource.Severity {
+	case health.SeverityError:
+		return "✗"
+	case health.SeverityWarning:
+		return "!"
+	}
+	return "✓"
+}
+
+// SetResources replaces the resources and keeps the selected row when it still exists.
+func (t *ResourceTable) SetResources(resources []health.ResourceHealth) {
+	t.resources = resources
+	t.refresh()
+}
+
+// Selected returns the resource of the selected row.
+func (t *ResourceTable) Selected() (health.ResourceHealth, bool) {
+	cursor := t.table.Cursor()
+	if cursor < 0 || cursor >= len(t.visible) {
+		return health.ResourceHealth{}, false
+	}
+	return t.visible[cursor], true
+}
+
+// Capturing reports whether the keys are typed in the filter and must not be handled by the parent view.
+func (t *ResourceTable) Capturing() bool {
+	return t.filtering
+}
+
+// Issues returns the number of resources that need attention, the collapsed failures excluded.
+func (t *ResourceTable) Issues() int {
+	return len(health.Issues(t.resources)) - t.collapsed()
+}
+
+// collapsed returns the number of failures collapsed under their root cause.
+func (t *ResourceTable) collapsed() int {
+	collapsed := 0
+	for _, resource := range t.resources {
+		if resource.RootCause != nil {
+			collapsed++
+		}
+	}
+	return collapsed
+}
+
+// Len returns the number of resources of the table.
+func (t *ResourceTable) Len() int {
+	return len(t.resources)
+}
+
+// SetSize sets the space available for the table, the filter and the detail pane.
+func (t *ResourceTable) SetSize(width int, height int) {
+	t.width, t.height = width, height
+	t.layout()
+}
+
+// refresh filters and sorts the resources, then rebuilds the rows.
+func (t *ResourceTable) refresh() {
+	selected, hasSelected := t.Selected()
+	query := strings.ToLower(t.filter.Value())
+	t.visible = make([]health.ResourceHealth, 0, len(t.resources))
+	for _, resource := range t.resources {
+		if t.issuesOnly && !resource.IsIssue() {
+			continue
+		}
+		if !t.showCollapsed && resource.RootCause != nil {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(strings.Join(append(t.row(resource), resource.Message), " ")), query) {
+			continue
+		}
+		t.visible = append(t.visible, resource)
+	}
+	sort.SliceStable(t.visible, func(i, j int) bool {
+		if t.sortDesc {
+			return t.less(t.visible[j], t.visible[i])
+		}
+		return t.less(t.visible[i], t.visible[j])
+	})
+
+	rows := make([]table.Row, 0, len(t.visible))
+	cursor := 0
+	for i, resource := range t.visible {
+		rows = append(rows, t.row(resource))
+		if hasSelected && resource.Kind == selected.Kind && resource.Namespace == selected.Namespace && resource.Name == selected.Name {
+			cursor = i
+		}
+	}
+	// The columns are sized before the rows are set, the table renders the rows with the current columns.
+	t.table.SetRows(nil)
+	t.table.SetColumns(t.columns(rows))
+	t.table.SetRows(rows)
+	t.table.SetCursor(cursor)
+}
+
+// less compares two resources on the sort column. The marker column sorts by severity, most severe first.
+func (t *ResourceTable) less(a health.ResourceHealth, b health.ResourceHealth) bool {
+	if t.sortColumn == 0 {
+		return a.Severity > b.Severity
+	}
+	return t.row(a)[t.sortColumn] < t.row(b)[t.sortColumn]
+}
+
+// columns sizes the columns to their content, the last column taking the remaining width.
+func (t *ResourceTable) columns(rows []table.Row) []table.Column {
+	headers := t.headers()
+	columns := make([]table.Column, len(headers))
+	used := 0
+	for i, header := range headers {
+		title := header
+		if i == t.sortColumn {
+			title += map[bool]string{false: " ▲", true: " ▼"}[t.sortDesc]
+		}
+		width := runewidth.StringWidth(title)
+		for _, row := range rows {
+			width = max(width, runewidth.StringWidth(row[i]))
+		}
+		if i < len(headers)-1 {
+			width = min(width, maxColumnWidth)
+			used += width + cellStyle.GetHorizontalPadding()
+		}
+		columns[i] = table.Column{Title: title, Width: width}
+	}
+	if t.width > 0 {
+		last := len(columns) - 1
+		columns[last].Width = max(t.width-used-cellStyle.GetHorizontalPadding(), 10)
+	}
+	return columns
+}
+
+func (t *ResourceTable) layout() {
+	height := t.height - 2 // filter line and help line
+	if t.showDetail {
+		height -= detailHeight + 2
+	}
+	t.table.SetWidth(t.width)
+	t.table.SetHeight(max(height-1, 3)) // header line
+	t.refresh()
+}
+
+// Update handles the keys of the table.
+func (t *ResourceTable) Update(msg tea.Msg) tea.Cmd {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+	if t.filtering {
+		switch keyMsg.String() {
+		case "enter":
+			t.filtering = false
+			t.filter.Blur()
+		case "esc":
+			t.filtering = false
+			t.filter.Blur()
+			t.filter.SetValue("")
+		default:
+			var cmd tea.Cmd
+			t.filter, cmd = t.filter.Update(msg)
+			t.refresh()
+			return cmd
+		}
+		t.refresh()
+		return nil
+	}
+	switch keyMsg.String() {
+	case "/":
+		t.filtering = true
+		return t.filter.Focus()
+	case "s":
+		t.sortColumn = (t.sortColumn + 1) % len(t.headers())
+		t.refresh()
+	case "S":
+		t.sortDesc = !t.sortDesc
+		t.refresh()
+	case "i":
+		t.issuesOnly = !t.issuesOnly
+		t.refresh()
+	case "c":
+		t.showCollapsed = !t.showCollapsed
+		t.refresh()
+	case "enter":
+		t.showDetail = !t.showDetail
+		t.detailOffset = 0
+		t.layout()
+	case "J":
+		t.detailOffset++
+	case "K":
+		t.detailOffset = max(t.detailOffset-1, 0)
+	case "esc":
+		if t.showDetail {
+			t.showDetail = false
+			t.layout()
+		} else if t.filter.Value() != "" {
+			t.filter.SetValue("")
+			t.refresh()
+		}
+	default:
+		var cmd tea.Cmd
+		t.table, cmd = t.table.Update(msg)
+		t.detailOffset = 0
+		return cmd
+	}
+	return nil
+}
+
+// View renders the filter, the table, the detail pane and the key help.
+func (t *ResourceTable) View() string {
+	filterLine := helpStyle.Render(fmt.Sprintf("%d/%d shown", len(t.visible), len(t.resources)))
+	if t.issuesOnly {
+		filterLine += warningStyle.Render(" • issues only")
+	}
+	if collapsed := t.collapsed(); collapsed > 0 && !t.showCollapsed {
+		filterLine += helpStyle.Render(fmt.Sprintf(" • %d collapsed under their root cause", collapsed))
+	}
+	if t.filtering || t.filter.Value() != "" {
+		filterLine = t.filter.View() + "  " + filterLine
+	}
+	sections := []string{filterLine, t.table.View()}
+	if t.showDetail {
+		if resource, ok := t.Selected(); ok {
+			sections = append(sections, t.detail(resource))
+		}
+	}
+	sections = append(sections, helpStyle.Render("↑/↓: move • /: filter • s/S: sort column/order • i: issues only • c: collapsed • enter: details • J/K: scroll details"))
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+// detail renders the full message, the hints, the conditions and the events of a resource.
+func (t *ResourceTable) detail(resource health.ResourceHealth) string {
+	lines := []string{labelStyle.Render(fmt.Sprintf("%s %s/%s", resource.Kind, resource.Namespace, resource.Name))}
+	status := fmt.Sprintf("%s • status %s • reason %s", resource.Severity, resource.Status, resource.Reason)
+	switch resource.Severity {
+	case health.SeverityError:
+		status = errorStyle.Render(status)
+	case health.SeverityWarning:
+		status = warningStyle.Render(status)
+	}
+	lines = append(lines, status)
+	if resource.Message != "" {
+		lines = append(lines, labelStyle.Render("Message"), resource.Message)
+	}
+	if resource.Owner != nil {
+		lines = append(lines, labelStyle.Render("Owner")+" "+resource.Owner.String())
+	}
+	if resource.ManagedBy != nil {
+		lines = append(lines, labelStyle.Render("Managed by")+" "+resource.ManagedBy.String())
+	}
+	if resource.RootCause != nil {
+		lines = append(lines, labelStyle.Render("Root cause"), resource.RootCause.String())
+	}
+	if len(resource.Downstream) > 0 {
+		lines = append(lines, labelStyle.Render("Failing downstream"))
+		for _, downstream := range resource.Downstream {
+			lines = append(lines, "• "+downstream.String())
+		}
+	}
+	if len(resource.Hints) > 0 {
+		lines = append(lines, labelStyle.Render("Hints"))
+		for _, hint := range resource.Hints {
+			lines = append(lines, "• "+hint.String())
+		}
+	}
+	if len(resource.Conditions) > 0 {
+		lines = append(lines, labelStyle.Render("Conditions"))
+		for _, condition := range resource.Conditions {
+			line := fmt.Sprintf("• %s=%s", condition.Type, condition.Status)
+			if condition.Reason != "" {
+				line += " (" + condition.Reason + ")"
+			}
+			if condition.Message != "" {
+				line += ": " + condition.Message
+			}
+			lines = append(lines, line)
+		}
+	}
+	if len(resource.Events) > 0 {
+		lines = append(lines, labelStyle.Render("Events"))
+		for _, event := range resource.Events {
+			lines = append(lines, fmt.Sprintf("• %dx %s: %s", event.Count, event.Reason, event.Message))
+		}
+	}
+	for _, logs := range resource.Logs {
+		title := "Logs " + logs.Container
+		if logs.Previous {
+			title += " (previous)"
+		}
+		lines = append(lines, labelStyle.Render(title))
+		lines = append(lines, logs.Lines...)
+	}
+	width := max(t.width-4, 20)
+	content := lipgloss.NewStyle().Width(width).Render(strings.Join(lines, "\n"))
+	contentLines := strings.Split(content, "\n")
+	if len(contentLines) > detailHeight {
+		t.detailOffset = min(t.detailOffset, len(contentLines)-detailHeight+1)
+		remaining := len(contentLines) - t.detailOffset - detailHeight + 1
+		contentLines = contentLines[t.detailOffset : t.detailOffset+detailHeight-1]
+		if remaining > 0 {
+			contentLines = append(contentLines, helpStyle.Render(fmt.Sprintf("… %d more lines (J/K: scroll)", remaining)))
+		}
+	}
+	return detailStyle.Render(strings.Join(contentLines, "\n"))
+}
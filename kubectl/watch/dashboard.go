@@ -0,0 +1,183 @@
+package watch
+
+import (
+	"fmt"
+	"kubectl/charm"
+	"kubectl/health"
+	"kubectl/logger"
+	"kubectl/remediate"
+	"kubectl/scan"
+	"kubectl/tui"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// highlightFor is how long a row stays highlighted after it changed.
+const highlightFor = 10 * time.Second
+
+var (
+	errorStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+type snapshotMsg struct {
+	kinds []scan.KindResult
+	err   error
+}
+
+type tickMsg time.Time
+
+type rowState struct {
+	fingerprint string
+	changedAt   time.Time
+}
+
+// Dashboard is the full screen view of the watch mode. It renders the latest snapshot of the watcher,
+// one tab per kind, and marks the rows that changed recently.
+type Dashboard struct {
+	watcher    *Watcher
+	context    string
+	namespaces []string
+	kinds      []scan.KindResult
+	browser    *tui.Browser
+	rows       map[string]rowState
+	loaded     bool
+	updatedAt  time.Time
+	err        error
+}
+
+func NewDashboard(watcher *Watcher, kubeContext string, namespaces []string, actions []remediate.Action) *Dashboard {
+	d := &Dashboard{watcher: watcher, context: kubeContext, namespaces: namespaces, rows: make(map[string]rowState), browser: &tui.Browser{Actions: actions}}
+	d.browser.Highlight = d.recentlyChanged
+	return d
+}
+
+// Run starts the informers and shows the dashboard until the user quits.
+// The actions can be run on the selected rows.
+func Run(watcher *Watcher, kubeContext string, namespaces []string, actions []remediate.Action) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	logger.Logger.Info("Syncing informer caches", "context", kubeContext, "namespaces", strings.Join(namespaces, ","))
+	if err := watcher.Start(stop); err != nil {
+		return err
+	}
+	_, err := tea.NewProgram(NewDashboard(watcher, kubeContext, namespaces, actions), tea.WithAltScreen()).Run()
+	return err
+}
+
+func (d *Dashboard) snapshot() tea.Msg {
+	kinds, err := d.watcher.Snapshot()
+	return snapshotMsg{kinds: kinds, err: err}
+}
+
+func (d *Dashboard) waitForChange() tea.Msg {
+	<-d.watcher.Changes()
+	return d.snapshot()
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (d *Dashboard) Init() tea.Cmd {
+	return tea.Batch(d.snapshot, tick())
+}
+
+func (d *Dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.browser.SetSize(msg.Width, msg.Height-4) // header and help lines
+		return d, nil
+	case tea.KeyMsg:
+		if !d.browser.Capturing() && (msg.String() == "q" || msg.String() == "ctrl+c") {
+			return d, tea.Quit
+		}
+	case tickMsg:
+		// Refresh the markers of the rows whose highlight expired.
+		d.browser.SetKinds(d.kinds, len(d.namespaces) > 1)
+		return d, tick()
+	case snapshotMsg:
+		d.err = msg.err
+		if msg.err == nil {
+			d.apply(msg.kinds)
+		}
+		return d, d.waitForChange
+	}
+	return d, d.browser.Update(msg)
+}
+
+// apply replaces the displayed snapshot and records the rows whose content changed.
+func (d *Dashboard) apply(kinds []scan.KindResult) {
+	now := time.Now()
+	rows := make(map[string]rowState)
+	for _, kind := range kinds {
+		for _, resource := range kind.Resources {
+			key := rowKey(resource)
+			fingerprint := resource.Severity.String() + "\x00" + strings.Join(kind.Table.Row(resource), "\x00")
+			previous, seen := d.rows[key]
+			switch {
+			case seen && previous.fingerprint == fingerprint:
+				rows[key] = previous
+			case d.loaded:
+				rows[key] = rowState{fingerprint: fingerprint, changedAt: now}
+			default:
+				rows[key] = rowState{fingerprint: fingerprint}
+			}
+		}
+	}
+	d.rows = rows
+	d.kinds = kinds
+	d.loaded = true
+	d.updatedAt = now
+	d.browser.SetKinds(kinds, len(d.namespaces) > 1)
+}
+
+// recentlyChanged reports whether the row of the resource changed less than highlightFor ago.
+func (d *Dashboard) recentlyChanged(resource health.ResourceHealth) bool {
+	state, ok := d.rows[rowKey(resource)]
+	return ok && !state.changedAt.IsZero() && time.Since(state.changedAt) < highlightFor
+}
+
+func rowKey(resource health.ResourceHealth) string {
+	return resource.Kind + "/" + resource.Namespace + "/" + resource.Name
+}
+
+func (d *Dashboard) View() string {
+	if !d.loaded {
+		return "Loading..."
+	}
+	sections := []string{d.header()}
+	if d.err != nil {
+		sections = append(sections, errorStyle.Render("snapshot failed: "+d.err.Error()))
+	}
+	sections = append(sections, d.browser.View(), helpStyle.Render("tab/shift+tab: switch kind • t: dependencies • ●: changed recently • q: quit"))
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+// header summarizes the watched scope and the number of issues.
+func (d *Dashboard) header() string {
+	total, errors, warnings := 0, 0, 0
+	for _, kind := range d.kinds {
+		for _, resource := range kind.Resources {
+			total++
+			switch resource.Severity {
+			case health.SeverityError:
+				errors++
+			case health.SeverityWarning:
+				warnings++
+			}
+		}
+	}
+	title := charm.TitleStyle.Render(fmt.Sprintf("Watching %s • %s", d.context, strings.Join(d.namespaces, ", ")))
+	summary := strings.Join([]string{
+		fmt.Sprintf("%d objects", total),
+		errorStyle.Render(fmt.Sprintf("%d errors", errors)),
+		warningStyle.Render(fmt.Sprintf("%d warnings", warnings)),
+		helpStyle.Render("updated " + d.updatedAt.Format("15:04:05")),
+	}, " • ")
+	return title + "\n" + summary
+}
@@ -0,0 +1,216 @@
+package watch
+
+import (
+	"fmt"
+	"kubectl/customresource"
+	"kubectl/health"
+	"kubectl/k8s"
+	"kubectl/scan"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSyncTimeout is the time given to the informers to list the objects before the watch is aborted.
+const cacheSyncTimeout = time.Minute
+
+type crInformer struct {
+	cr       customresource.CustomResourceDefinition
+	informer informers.GenericInformer
+}
+
+// Watcher keeps the pods, warning events and custom resources of the namespaces in shared informer caches
+// and signals every change.
+type Watcher struct {
+	namespaces     map[string]bool
+	factory        informers.SharedInformerFactory
+	eventFactory   informers.SharedInformerFactory
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+	podLister      corelisters.PodLister
+	eventLister    corelisters.EventLister
+	crInformers    []crInformer
+	changes        chan struct{}
+	watchErrors    chan error
+	cacheSyncs     []cache.InformerSynced
+}
+
+// NewWatcher creates the informers of the namespaces. A single namespace is watched with namespaced informers,
+// several namespaces with cluster wide informers whose objects are filtered.
+func NewWatcher(kubeClient kubernetes.Interface, kubeDynamicClient dynamic.Interface, namespaces []string, crds []customresource.CustomResourceDefinition, resync time.Duration) (*Watcher, error) {
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("no namespace to watch")
+	}
+	w := &Watcher{namespaces: make(map[string]bool), changes: make(chan struct{}, 1), watchErrors: make(chan error, 1)}
+	for _, namespace := range namespaces {
+		w.namespaces[namespace] = true
+	}
+	informerNamespace := metav1.NamespaceAll
+	if len(namespaces) == 1 {
+		informerNamespace = namespaces[0]
+	}
+	w.factory = informers.NewSharedInformerFactoryWithOptions(kubeClient, resync, informers.WithNamespace(informerNamespace))
+	// Only the warning events are cached, the normal ones would trigger a new snapshot without changing it.
+	w.eventFactory = informers.NewSharedInformerFactoryWithOptions(kubeClient, resync, informers.WithNamespace(informerNamespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) { options.FieldSelector = "type!=Normal" }))
+	w.dynamicFactory = dynamicinformer.NewFilteredDynamicSharedInformerFactory(kubeDynamicClient, resync, informerNamespace, nil)
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { w.notify() },
+		UpdateFunc: func(interface{}, interface{}) { w.notify() },
+		DeleteFunc: func(interface{}) { w.notify() },
+	}
+	podInformer := w.factory.Core().V1().Pods()
+	eventInformer := w.eventFactory.Core().V1().Events()
+	for _, informer := range []cache.SharedIndexInformer{podInformer.Informer(), eventInformer.Informer()} {
+		if err := w.register(informer, handler); err != nil {
+			return nil, err
+		}
+	}
+	w.podLister = podInformer.Lister()
+	w.eventLister = eventInformer.Lister()
+
+	for _, cr := range crds {
+		// The informers are scoped to the watched namespaces, cluster scoped resources are left to the full analysis.
+		if cr.IsClusterScoped() {
+			continue
+		}
+		informer := w.dynamicFactory.ForResource(cr.GetGVR())
+		if err := w.register(informer.Informer(), handler); err != nil {
+			return nil, err
+		}
+		w.crInformers = append(w.crInformers, crInformer{cr: cr, informer: informer})
+	}
+	return w, nil
+}
+
+// register adds the change handler to an informer and records the list and watch errors that cannot be retried.
+func (w *Watcher) register(informer cache.SharedIndexInformer, handler cache.ResourceEventHandler) error {
+	if _, err := informer.AddEventHandler(handler); err != nil {
+		return err
+	}
+	err := informer.SetWatchErrorHandler(func(r *cache.Reflector, err error) {
+		cache.DefaultWatchErrorHandler(r, err)
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) || apierrors.IsNotFound(err) {
+			select {
+			case w.watchErrors <- err:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	w.cacheSyncs = append(w.cacheSyncs, informer.HasSynced)
+	return nil
+}
+
+// Start runs the informers until stop is closed and waits for their caches to be synced.
+// The wait is aborted when an informer is not allowed to list its objects or after cacheSyncTimeout.
+func (w *Watcher) Start(stop <-chan struct{}) error {
+	w.factory.Start(stop)
+	w.eventFactory.Start(stop)
+	w.dynamicFactory.Start(stop)
+	synced := make(chan struct{})
+	defer close(synced)
+	syncStop := make(chan struct{})
+	syncErr := fmt.Errorf("failed syncing the informer caches")
+	go func() {
+		defer close(syncStop)
+		select {
+		case err := <-w.watchErrors:
+			syncErr = fmt.Errorf("failed syncing the informer caches: %w", err)
+		case <-time.After(cacheSyncTimeout):
+			syncErr = fmt.Errorf("informer caches not synced within %s", cacheSyncTimeout)
+		case <-stop:
+		case <-synced:
+		}
+	}()
+	if !cache.WaitForCacheSync(syncStop, w.cacheSyncs...) {
+		<-syncStop
+		return syncErr
+	}
+	return nil
+}
+
+// Changes receives a value after one or more objects changed. Changes are coalesced until the value is read.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+func (w *Watcher) notify() {
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Snapshot evaluates the health of the objects currently in the caches, one result per kind.
+// Custom resources come first in the order of the registry, followed by the pods.
+func (w *Watcher) Snapshot() ([]scan.KindResult, error) {
+	events, err := w.eventLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	eventsByNamespace := make(map[string][]corev1.Event)
+	for _, event := range events {
+		eventsByNamespace[event.Namespace] = append(eventsByNamespace[event.Namespace], *event)
+	}
+	eventIndexes := make(map[string]k8s.EventIndex)
+	for namespace, namespaceEvents := range eventsByNamespace {
+		eventIndexes[namespace] = k8s.IndexWarningEvents(namespaceEvents)
+	}
+
+	kinds := make([]scan.KindResult, 0, len(w.crInformers)+1)
+	for _, crInf := range w.crInformers {
+		objects, err := crInf.informer.Lister().List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		resources := make([]health.ResourceHealth, 0, len(objects))
+		for _, object := range objects {
+			custom, ok := object.(*unstructured.Unstructured)
+			if !ok || !w.namespaces[custom.GetNamespace()] {
+				continue
+			}
+			resources = append(resources, crInf.cr.EvaluateResource(custom, eventIndexes[custom.GetNamespace()]))
+		}
+		kinds = append(kinds, scan.KindResult{Kind: crInf.cr.GetPrettyName(), Table: health.CustomResourceTable, Resources: sortResources(resources)})
+	}
+
+	pods, err := w.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	podList := make([]health.ResourceHealth, 0, len(pods))
+	for _, pod := range pods {
+		if !w.namespaces[pod.Namespace] {
+			continue
+		}
+		podList = append(podList, k8s.GetPodStatuses(eventIndexes[pod.Namespace], pod.Namespace, pod))
+	}
+	kinds = append(kinds, scan.KindResult{Kind: "Pod", Table: health.PodTable, Resources: sortResources(podList)})
+	scan.DependencyGraph([]scan.NamespaceResult{{Kinds: kinds}}).Propagate()
+	return kinds, nil
+}
+
+// sortResources orders the resources by namespace and name, the informer caches being unordered.
+func sortResources(resources []health.ResourceHealth) []health.ResourceHealth {
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Namespace != resources[j].Namespace {
+			return resources[i].Namespace < resources[j].Namespace
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	return resources
+}
@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// EventIndex holds the warning events of a namespace indexed by involved object kind and name.
+type EventIndex map[string][]corev1.Event
+
+func eventKey(kind string, name string) string {
+	return kind + "/" + name
+}
+
+// GetWarningEvents lists the warning events of the namespace once and indexes them by involved object.
+// Events of an object are sorted from the most recent and deduplicated by reason, the counts being summed.
+func GetWarningEvents(kubeClient kubernetes.Interface, namespace string) (EventIndex, error) {
+	events, err := kubeClient.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{FieldSelector: "type!=Normal"})
+	if err != nil {
+		log.Error("Failed listing events ", err)
+		return nil, err
+	}
+	index := EventIndex{}
+	for _, event := range events.Items {
+		key := eventKey(event.InvolvedObject.Kind, event.InvolvedObject.Name)
+		index[key] = append(index[key], event)
+	}
+	for key, objectEvents := range index {
+		index[key] = dedupeEvents(objectEvents)
+	}
+	return index, nil
+}
+
+// For returns the warning events of the object, most recent first.
+func (idx EventIndex) For(kind string, name string) []corev1.Event {
+	return idx[eventKey(kind, name)]
+}
+
+func dedupeEvents(events []corev1.Event) []corev1.Event {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).After(eventTime(events[j]))
+	})
+	deduped := make([]corev1.Event, 0, len(events))
+	byReason := map[string]int{}
+	for _, event := range events {
+		if event.Count == 0 {
+			event.Count = 1
+		}
+		if i, ok := byReason[event.Reason]; ok {
+			deduped[i].Count += event.Count
+			continue
+		}
+		byReason[event.Reason] = len(deduped)
+		deduped = append(deduped, event)
+	}
+	return deduped
+}
+
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
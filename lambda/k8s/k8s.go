@@ -6,7 +6,6 @@ import (
 	"lambda/logger"
 	"lambda/requests"
 	"os"
-	"path/filepath"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -37,21 +36,29 @@ func PathExists(path string) (bool, error) {
 	return !info.IsDir(), nil
 }
 
-func GetKubeConfigPath() string {
-	userHomeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Warn(err)
-	}
-	kubeConfigPath := filepath.Join(userHomeDir, ".kube", "config")
-	return kubeConfigPath
+// GetKubeConfigLoadingRules returns the standard kubeconfig loading rules: every file listed in
+// KUBECONFIG is merged, ~/.kube/config is used otherwise, and an explicit path takes precedence over both.
+func GetKubeConfigLoadingRules(explicitPath string) *clientcmd.ClientConfigLoadingRules {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = explicitPath
+	return loadingRules
 }
 
-func GetKubeConfigFromFile(kubeConfigPath string) *api.Config {
-	config, err := clientcmd.LoadFromFile(kubeConfigPath)
-	if err != nil {
-		log.Error(err)
+// kubeConfigFilesExist reports whether at least one of the kubeconfig files selected by the loading rules exists.
+// A missing explicit path is an error rather than a fallback to the in-cluster config.
+func kubeConfigFilesExist(loadingRules *clientcmd.ClientConfigLoadingRules) (bool, error) {
+	if loadingRules.ExplicitPath != "" {
+		if exists, _ := PathExists(loadingRules.ExplicitPath); !exists {
+			return false, fmt.Errorf("kubeconfig %s not found", loadingRules.ExplicitPath)
+		}
+		return true, nil
 	}
-	return config
+	for _, path := range loadingRules.GetLoadingPrecedence() {
+		if exists, _ := PathExists(path); exists {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func GetKubeContexts(config *api.Config) []string {
@@ -78,9 +85,14 @@ func SwitchKubeContextNamespace(context string, kubeConfigPath string, namespace
 	}
 }
 
-func CreateKubeClient(kubeConfigPath string, clientType string) (interface{}, error) {
+func CreateKubeClient(loadingRules *clientcmd.ClientConfigLoadingRules, clientType string) (interface{}, error) {
 	var kubeConfig *rest.Config
-	if _, err := PathExists(kubeConfigPath); err != nil {
+	filesExist, err := kubeConfigFilesExist(loadingRules)
+	if err != nil {
+		log.Error("Failed to load kube config ", err)
+		return nil, err
+	}
+	if !filesExist {
 		log.Info("Loading in-cluster kube config")
 		kubeConfig, err = rest.InClusterConfig()
 		if err != nil {
@@ -88,8 +100,8 @@ func CreateKubeClient(kubeConfigPath string, clientType string) (interface{}, er
 			return nil, err
 		}
 	} else {
-		log.Info("Loading kube config ", kubeConfigPath)
-		kubeConfig, err = clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+		log.Info("Loading kube config ", strings.Join(loadingRules.GetLoadingPrecedence(), string(os.PathListSeparator)))
+		kubeConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
 		if err != nil {
 			log.Error("Failed to load kube config from path ", err)
 			return nil, err
@@ -174,7 +186,7 @@ func GetNamespacesList(kubeClient kubernetes.Interface) (*corev1.NamespaceList,
 	return namespaces, nil
 }
 
-func GetPodStatuses(kubeClient kubernetes.Interface, namespace string, pod *corev1.Pod) (map[string]string, []corev1.Event) {
+func GetPodStatuses(events EventIndex, namespace string, pod *corev1.Pod) (map[string]string, []corev1.Event) {
 	podStatuses := map[string]string{
 		"podReady":             "",
 		"initialized":          "",
@@ -190,7 +202,7 @@ func GetPodStatuses(kubeClient kubernetes.Interface, namespace string, pod *core
 			podStatuses["podReady"] = string(condition.Status)
 			if podStatuses["podReady"] != "True" {
 				podStatuses["reason"] = condition.Reason
-				podEvents = GetWarningEventsFromResource(kubeClient, "Pod", namespace, pod.Name)
+				podEvents = events.For("Pod", pod.Name)
 				for _, c := range pod.Status.ContainerStatuses {
 					if c.State.Waiting != nil && c.State.Waiting.Reason != "" {
 						podStatuses["waitingReason"] = c.State.Waiting.Reason
@@ -224,12 +236,16 @@ func GetPodListErrors(kubeClient kubernetes.Interface, namespace string) ([][]st
 	if err != nil {
 		log.Error(err)
 	}
+	events, err := GetWarningEvents(kubeClient, namespace)
+	if err != nil {
+		log.Error(err)
+	}
 
 	podList := make([][]string, 0)
 	podListIssue := make([]map[string]string, 0)
 
 	for _, pod := range pods.Items {
-		podStatuses, podEvents := GetPodStatuses(kubeClient, namespace, &pod)
+		podStatuses, podEvents := GetPodStatuses(events, namespace, &pod)
 		podRow := []string{
 			pod.ObjectMeta.Name,
 			string(pod.Status.Phase),
@@ -260,22 +276,6 @@ func GetPodListErrors(kubeClient kubernetes.Interface, namespace string) ([][]st
 	return podList, podListIssue
 }
 
-func GetWarningEventsFromResource(kubeClient kubernetes.Interface, resourceKind string, namespace string, resourceName string) []corev1.Event {
-	var warningEvents []corev1.Event
-	events, err := kubeClient.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		log.Error(err)
-	}
-	for _, event := range events.Items {
-		if event.InvolvedObject.Kind == resourceKind && event.InvolvedObject.Name == resourceName {
-			if event.Type != "Normal" {
-				warningEvents = append(warningEvents, event)
-			}
-		}
-	}
-	return warningEvents
-}
-
 func UpdateResource(ctx context.Context, dynamicClient dynamic.Interface, obj runtime.Object, modifyRequest requests.ModifyRequest) error {
 	metaObj, ok := obj.(metav1.Object)
 	if !ok {
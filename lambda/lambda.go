@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"lambda/k8s"
 	"lambda/logger"
 	"lambda/server"
@@ -12,14 +13,17 @@ import (
 )
 
 func main() {
+	kubeConfigPath := flag.String("kubeconfig", "", "path to the kubeconfig file, KUBECONFIG files are merged when empty")
+	flag.Parse()
+
 	logger.InitLogger()
-	kubeConfigPath := k8s.GetKubeConfigPath()
+	loadingRules := k8s.GetKubeConfigLoadingRules(*kubeConfigPath)
 
-	kubeClient, err := k8s.CreateKubeClient(kubeConfigPath, "static")
+	kubeClient, err := k8s.CreateKubeClient(loadingRules, "static")
 	if err != nil {
 		log.Fatal("Error creating static kube client ", err)
 	}
-	kubeDynamicClient, err := k8s.CreateKubeClient(kubeConfigPath, "dynamic")
+	kubeDynamicClient, err := k8s.CreateKubeClient(loadingRules, "dynamic")
 	if err != nil {
 		log.Fatal("Error creating dynamic kube client ", err)
 	}